@@ -0,0 +1,629 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vl4deee11/aalive/eventlog"
+	"github.com/vl4deee11/aalive/sim"
+)
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// maxBody caps REST request bodies.
+const maxBody = 1 << 20
+
+// writeError answers with a failed reply, the one error shape of the API.
+// op names the operation like a command type does.
+func writeError(w http.ResponseWriter, op string, err error) {
+	rep := newReply(commandHeader{Type: op}, 0, nil, err)
+	writeJSON(w, rep.status(), rep)
+}
+
+// restOp names a request that is not a command in error replies.
+func restOp(r *http.Request) string {
+	return r.Method + " " + r.URL.Path
+}
+
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, &replyError{Code: codeTooLarge, Message: "body larger than " + strconv.Itoa(maxBody) + " bytes"}
+	}
+	if err != nil {
+		return nil, &replyError{Code: codeBadRequest, Message: err.Error()}
+	}
+	return raw, nil
+}
+
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, restOp(r), &replyError{Code: codeMethodNotAllowed, Message: "method not allowed"})
+	return false
+}
+
+type api struct {
+	worlds *worldManager
+	auth   *authConfig
+}
+
+func registerAPI(mux *http.ServeMux, worlds *worldManager, auth *authConfig) {
+	a := &api{worlds: worlds, auth: auth}
+
+	mux.HandleFunc("/api/agents/", a.agentHistory)
+	mux.HandleFunc("/api/metrics", a.metricsHistory)
+	mux.HandleFunc("/api/events", a.events)
+	mux.HandleFunc("/api/traits", a.traits)
+
+	mux.HandleFunc("/api/v1/state", a.state)
+	mux.HandleFunc("/api/v1/agents", a.agents)
+	mux.HandleFunc("/api/v1/agents/", a.agent)
+	mux.HandleFunc("/api/v1/foods", a.foods)
+	mux.HandleFunc("/api/v1/config", a.config)
+	mux.HandleFunc("/api/v1/commands", a.commands)
+	mux.HandleFunc("/api/v1/metrics", a.metricsHistory)
+	mux.HandleFunc("/api/v1/events", a.events)
+	mux.HandleFunc("/api/v1/traits", a.traits)
+	mux.HandleFunc("/api/v1/demonstrations", a.demonstrations)
+	mux.HandleFunc("/api/v1/brains", a.brainList)
+	mux.HandleFunc("/api/v1/brains/", a.brainItem)
+	mux.HandleFunc("/api/v1/worlds", a.worldList)
+	mux.HandleFunc("/api/v1/worlds/", a.worldItem)
+}
+
+func (a *api) world(w http.ResponseWriter, r *http.Request) (*world, bool) {
+	wd, ok := a.worlds.get(r.URL.Query().Get("world"))
+	if !ok {
+		writeError(w, restOp(r), errWorldNotFound)
+	}
+	return wd, ok
+}
+
+func (a *api) permit(w http.ResponseWriter, r *http.Request, op string, need role) bool {
+	have, err := a.auth.roleFor(r)
+	if err == nil {
+		err = checkRole(have, need, op)
+	}
+	if err != nil {
+		rep := newReply(commandHeader{Type: op}, 0, nil, err)
+		writeJSON(w, rep.status(), rep)
+		return false
+	}
+	return true
+}
+
+func (a *api) permitCommand(w http.ResponseWriter, r *http.Request, h commandHeader, raw []byte) bool {
+	have, err := a.auth.roleFor(r)
+	if err == nil {
+		err = authorizeCommand(have, h, raw)
+	}
+	if err != nil {
+		rep := newReply(h, 0, nil, err)
+		writeJSON(w, rep.status(), rep)
+		return false
+	}
+	return true
+}
+
+func (a *api) agentHistory(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	wd, ok := a.world(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/agents/"))
+	if err != nil {
+		writeError(w, "agent_history", errAgentID)
+		return
+	}
+	h, ok := wd.sim.AgentHistory(id)
+	if !ok {
+		writeError(w, "agent_history", sim.ErrAgentNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, h)
+}
+
+func (a *api) metricsHistory(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	wd, ok := a.world(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	from, _ := strconv.Atoi(q.Get("from"))
+	to, _ := strconv.Atoi(q.Get("to"))
+	resolution := 1
+	if v := q.Get("resolution"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, "metrics_history", fieldError(codeInvalidField, "resolution", "must be an integer"))
+			return
+		}
+		resolution = n
+	}
+	samples, err := wd.sim.MetricsHistory(from, to, resolution)
+	if err != nil {
+		writeError(w, "metrics_history", &replyError{Code: codeInvalidField, Message: err.Error()})
+		return
+	}
+	if q.Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=metrics.csv")
+		if err := sim.WriteMetricsCSV(w, samples); err != nil {
+			log.Printf("metrics csv: %v", err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, samples)
+}
+
+func (a *api) events(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	wd, ok := a.world(w, r)
+	if !ok {
+		return
+	}
+	if wd.logDir == "" {
+		writeError(w, "query_events", &replyError{Code: codeNotFound, Message: "event log disabled"})
+		return
+	}
+	q := r.URL.Query()
+	f := eventlog.Filter{Type: sim.EventType(q.Get("type"))}
+	f.Actor, _ = strconv.Atoi(q.Get("actor"))
+	f.FromTick, _ = strconv.Atoi(q.Get("from_tick"))
+	f.Offset, _ = strconv.Atoi(q.Get("offset"))
+	f.Limit, _ = strconv.Atoi(q.Get("limit"))
+	events, next, done, err := eventlog.Query(wd.logDir, f)
+	if err != nil {
+		writeError(w, "query_events", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"events": events, "next_offset": next, "done": done})
+}
+
+func (a *api) traits(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	wd, ok := a.world(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, wd.sim.TraitStats())
+}
+
+func (a *api) demonstrations(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodDelete) {
+		return
+	}
+	wd, ok := a.world(w, r)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodDelete {
+		if !a.permit(w, r, "clear_demonstrations", roleOperator) {
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"cleared": wd.sim.ClearDemonstrations()})
+		return
+	}
+	q := r.URL.Query()
+	agent, _ := strconv.Atoi(q.Get("agent"))
+	since, _ := strconv.Atoi(q.Get("since"))
+	demos := wd.sim.Demonstrations(agent, since)
+	if q.Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=demonstrations.csv")
+		if err := sim.WriteDemonstrationsCSV(w, demos); err != nil {
+			log.Printf("demonstrations csv: %v", err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, demos)
+}
+
+func (a *api) state(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	wd, ok := a.world(w, r)
+	if !ok {
+		return
+	}
+	st := wd.sim.State()
+	if st == nil {
+		writeError(w, "state", &replyError{Code: codeUnavailable, Message: "no state yet"})
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (a *api) agents(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	wd, ok := a.world(w, r)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, wd.sim.Agents())
+		return
+	}
+	if !a.permit(w, r, "add_agent", commandRoles["add_agent"]) {
+		return
+	}
+	h := commandHeader{Type: "add_agent"}
+	raw, err := readCommandBody(w, r, h.Type)
+	if err != nil {
+		writeError(w, h.Type, err)
+		return
+	}
+	id, data, err := cmdAddAgent(wd.sim, raw)
+	rep := newReply(h, id, data, err)
+	status := rep.status()
+	if rep.OK {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, rep)
+}
+
+// readCommandBody reads a REST body holding a command's fields and tags it
+// with the command type so the WebSocket handler can decode it.
+func readCommandBody(w http.ResponseWriter, r *http.Request, typ string) ([]byte, error) {
+	raw, err := readBody(w, r)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, &replyError{Code: codeBadRequest, Message: "malformed JSON: " + err.Error()}
+	}
+	fields["type"], _ = json.Marshal(typ)
+	return json.Marshal(fields)
+}
+
+func (a *api) agent(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/v1/agents/")
+	if strings.HasSuffix(rest, "/brain") {
+		a.agentBrain(w, r, strings.TrimSuffix(rest, "/brain"))
+		return
+	}
+	if !allowMethods(w, r, http.MethodGet, http.MethodPatch, http.MethodDelete) {
+		return
+	}
+	wd, ok := a.world(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(rest)
+	if err != nil {
+		writeError(w, restOp(r), errAgentID)
+		return
+	}
+	switch r.Method {
+	case http.MethodDelete:
+		if !a.permit(w, r, "kill_agent", commandRoles["kill_agent"]) {
+			return
+		}
+		h := commandHeader{Type: "kill_agent"}
+		rep := newReply(h, id, nil, wd.sim.KillAgent(id))
+		writeJSON(w, rep.status(), rep)
+		return
+	case http.MethodPatch:
+		if !a.permit(w, r, "edit_agent", commandRoles["edit_agent"]) {
+			return
+		}
+		h := commandHeader{Type: "edit_agent"}
+		raw, err := readBody(w, r)
+		if err != nil {
+			writeError(w, h.Type, err)
+			return
+		}
+		var e sim.AgentEdit
+		if err := decodeStrict(raw, &e); err != nil {
+			writeError(w, h.Type, err)
+			return
+		}
+		rid, data, err := editAgent(wd.sim, id, e)
+		rep := newReply(h, rid, data, err)
+		writeJSON(w, rep.status(), rep)
+		return
+	}
+	out := map[string]interface{}{"id": id}
+	view, alive := wd.sim.Agent(id)
+	if alive {
+		out["agent"] = view
+	}
+	h, known := wd.sim.AgentHistory(id)
+	if known {
+		out["history"] = h
+	}
+	if !alive && !known {
+		writeError(w, "get_agent", sim.ErrAgentNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+type brainUpload struct {
+	Name    string          `json:"name"`
+	SavedAt time.Time       `json:"saved_at"`
+	Origin  brainOrigin     `json:"origin"`
+	Brain   json.RawMessage `json:"brain"`
+}
+
+func readBrainUpload(w http.ResponseWriter, r *http.Request) (brainUpload, error) {
+	var u brainUpload
+	raw, err := readBody(w, r)
+	if err != nil {
+		return u, err
+	}
+	if err := decodeStrict(raw, &u); err != nil {
+		return u, err
+	}
+	if len(u.Brain) == 0 {
+		return u, fieldError(codeMissingField, "brain", "required")
+	}
+	return u, nil
+}
+
+func (a *api) agentBrain(w http.ResponseWriter, r *http.Request, idPart string) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+	wd, ok := a.world(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(idPart)
+	if err != nil {
+		writeError(w, restOp(r), errAgentID)
+		return
+	}
+	if r.Method == http.MethodGet {
+		f, err := exportBrain(wd.sim, &id, false)
+		if err != nil {
+			rep := newReply(commandHeader{Type: "export_brain"}, 0, nil, err)
+			writeJSON(w, rep.status(), rep)
+			return
+		}
+		w.Header().Set("Content-Disposition", "attachment; filename=brain-"+idPart+".json")
+		writeJSON(w, http.StatusOK, f)
+		return
+	}
+	if !a.permit(w, r, "load_brain", commandRoles["load_brain"]) {
+		return
+	}
+	h := commandHeader{Type: "load_brain"}
+	u, err := readBrainUpload(w, r)
+	var b sim.Brain
+	if err == nil {
+		b, err = resolveBrain(u.Brain)
+	}
+	if err == nil {
+		err = wd.sim.LoadBrain(id, b)
+	}
+	rep := newReply(h, id, nil, err)
+	writeJSON(w, rep.status(), rep)
+}
+
+func (a *api) brainList(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	list, err := library.list()
+	if err != nil {
+		writeError(w, "list_brains", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (a *api) brainItem(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPut, http.MethodDelete) {
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/api/v1/brains/")
+	switch r.Method {
+	case http.MethodGet:
+		f, err := library.load(name)
+		if err != nil {
+			rep := newReply(commandHeader{Type: "get_brain"}, 0, nil, err)
+			writeJSON(w, rep.status(), rep)
+			return
+		}
+		writeJSON(w, http.StatusOK, f)
+	case http.MethodPut:
+		overwrite := r.URL.Query().Get("overwrite") == "true"
+		need := roleOperator
+		if overwrite {
+			need = commandRoles["delete_brain"]
+		}
+		if !a.permit(w, r, "save_brain", need) {
+			return
+		}
+		h := commandHeader{Type: "save_brain"}
+		u, err := readBrainUpload(w, r)
+		f := brainFile{Name: name, SavedAt: u.SavedAt, Origin: u.Origin, Brain: sim.DefaultBrainSettings()}
+		if err == nil {
+			err = decodeStrict(u.Brain, &f.Brain)
+		}
+		if err == nil {
+			err = library.save(f, overwrite)
+		}
+		rep := newReply(h, 0, nil, err)
+		writeJSON(w, rep.status(), rep)
+	case http.MethodDelete:
+		if !a.permit(w, r, "delete_brain", commandRoles["delete_brain"]) {
+			return
+		}
+		if err := library.remove(name); err != nil {
+			rep := newReply(commandHeader{Type: "delete_brain"}, 0, nil, err)
+			writeJSON(w, rep.status(), rep)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (a *api) foods(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	wd, ok := a.world(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, wd.sim.Foods())
+}
+
+func (a *api) config(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPatch) {
+		return
+	}
+	wd, ok := a.world(w, r)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, wd.sim.Config())
+		return
+	}
+	if !a.permit(w, r, "update_config", roleAdmin) {
+		return
+	}
+	raw, err := readBody(w, r)
+	if err != nil {
+		writeError(w, "update_config", err)
+		return
+	}
+	var u sim.ConfigUpdate
+	if err := decodeStrict(raw, &u); err != nil {
+		writeError(w, "update_config", err)
+		return
+	}
+	cfg, err := wd.sim.UpdateConfig(u)
+	if err != nil {
+		writeError(w, "update_config", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+func (a *api) commands(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	wd, ok := a.world(w, r)
+	if !ok {
+		return
+	}
+	raw, err := readBody(w, r)
+	if err != nil {
+		writeError(w, "", err)
+		return
+	}
+	h, herr := parseHeader(raw)
+	if herr == nil && !a.permitCommand(w, r, h, raw) {
+		return
+	}
+	rep := executeCommand(wd.sim, raw)
+	writeJSON(w, rep.status(), rep)
+}
+
+func (a *api) worldList(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		list := a.worlds.list()
+		out := make([]worldInfo, 0, len(list))
+		for _, wd := range list {
+			out = append(out, wd.info())
+		}
+		writeJSON(w, http.StatusOK, out)
+		return
+	}
+	if !a.permit(w, r, "create_world", roleAdmin) {
+		return
+	}
+	h := commandHeader{Type: "create_world"}
+	raw, err := readBody(w, r)
+	if err != nil {
+		writeError(w, h.Type, err)
+		return
+	}
+	spec := worldSpec{Config: a.worlds.defaults}
+	spec.Seed = 0
+	if err := decodeStrict(raw, &spec); err != nil {
+		writeError(w, h.Type, err)
+		return
+	}
+	wd, err := a.worlds.create(spec)
+	if err != nil {
+		writeError(w, h.Type, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, wd.info())
+}
+
+func (a *api) worldItem(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost, http.MethodDelete) {
+		return
+	}
+	name, action := strings.TrimPrefix(r.URL.Path, "/api/v1/worlds/"), ""
+	if i := strings.Index(name, "/"); i >= 0 {
+		name, action = name[:i], name[i+1:]
+	}
+	wd, ok := a.worlds.get(name)
+	if !ok || name == "" {
+		writeError(w, restOp(r), errWorldNotFound)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && action == "":
+		writeJSON(w, http.StatusOK, wd.info())
+	case r.Method == http.MethodDelete && action == "":
+		if !a.permit(w, r, "destroy_world", roleAdmin) {
+			return
+		}
+		if err := a.worlds.destroy(name); err != nil {
+			rep := newReply(commandHeader{Type: "destroy_world"}, 0, nil, err)
+			writeJSON(w, rep.status(), rep)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && (action == "pause" || action == "resume"):
+		if !a.permit(w, r, action+"_world", roleOperator) {
+			return
+		}
+		wd.sim.SetPaused(action == "pause")
+		writeJSON(w, http.StatusOK, wd.info())
+	default:
+		writeError(w, restOp(r), &replyError{Code: codeNotFound, Message: "unknown world action"})
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+// newTestAPI serves the REST API for one small default world.
+func newTestAPI(t *testing.T, auth *authConfig) (http.Handler, *world) {
+	t.Helper()
+	cfg := sim.DefaultConfig()
+	cfg.Seed, cfg.Width, cfg.Height, cfg.InitialAgents = 1, 20, 20, 5
+	metrics := newServerMetrics()
+	worlds := newWorldManager("", cfg, metrics)
+	wd, err := worlds.create(worldSpec{Name: defaultWorld, Config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { wd.stop(metrics, "test done") })
+	mux := http.NewServeMux()
+	registerAPI(mux, worlds, auth)
+	return mux, wd
+}
+
+func doJSON(t *testing.T, h http.Handler, method, path, token, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var out map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("%s %s: non-JSON body %q", method, path, rec.Body.String())
+	}
+	return rec.Code, out
+}
+
+func errorCode(body map[string]interface{}) string {
+	e, _ := body["error"].(map[string]interface{})
+	code, _ := e["code"].(string)
+	return code
+}
+
+func TestUpdateConfig(t *testing.T) {
+	auth := &authConfig{anonymous: roleViewer, tokens: []authToken{{token: "root", role: roleAdmin}}}
+	h, wd := newTestAPI(t, auth)
+	tests := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+		code   string
+	}{
+		{"viewer", "", `{"max_age":10}`, http.StatusForbidden, codeForbidden},
+		{"valid", "root", `{"max_age":500,"random_food_prob":0.5,"tick_ms":50}`, http.StatusOK, ""},
+		{"prob above one", "root", `{"random_food_prob":2}`, http.StatusBadRequest, codeInvalidField},
+		{"negative max_age", "root", `{"max_age":-1}`, http.StatusBadRequest, codeInvalidField},
+		{"tick too short", "root", `{"tick_ms":1,"max_age":7}`, http.StatusBadRequest, codeInvalidField},
+		{"input wait too long", "root", `{"input_wait_ms":999999}`, http.StatusBadRequest, codeInvalidField},
+		{"unknown field", "root", `{"max_agee":5}`, http.StatusBadRequest, codeInvalidField},
+		{"wrong type", "root", `{"max_age":"5"}`, http.StatusBadRequest, codeInvalidField},
+		{"malformed", "root", `{"max_age":`, http.StatusBadRequest, codeInvalidField},
+		{"too large", "root", `{"max_age":1` + strings.Repeat(" ", maxBody) + `}`, http.StatusRequestEntityTooLarge, codeTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := doJSON(t, h, http.MethodPatch, "/api/v1/config", tt.token, tt.body)
+			if status != tt.status || errorCode(body) != tt.code {
+				t.Fatalf("got %d %v, want %d %q", status, body, tt.status, tt.code)
+			}
+		})
+	}
+	// Only the valid patch may have changed anything.
+	cfg := wd.sim.Config()
+	if cfg.MaxAge != 500 || cfg.RandomFoodProb != 0.5 || cfg.TickMillis != 50 || cfg.InputWaitMillis != sim.DefaultConfig().InputWaitMillis {
+		t.Fatalf("config after patches = %+v", cfg)
+	}
+}
+
+// TestErrorShape checks that failures across the API use the reply shape.
+func TestErrorShape(t *testing.T) {
+	auth := &authConfig{anonymous: roleAdmin}
+	h, _ := newTestAPI(t, auth)
+	tests := []struct {
+		method, path, body string
+		status             int
+		code               string
+	}{
+		{http.MethodPut, "/api/v1/state", "", http.StatusMethodNotAllowed, codeMethodNotAllowed},
+		{http.MethodGet, "/api/v1/state?world=nope", "", http.StatusNotFound, codeNotFound},
+		{http.MethodGet, "/api/v1/agents/x", "", http.StatusBadRequest, codeInvalidField},
+		{http.MethodGet, "/api/v1/agents/99999", "", http.StatusNotFound, codeNotFound},
+		{http.MethodGet, "/api/agents/x", "", http.StatusBadRequest, codeInvalidField},
+		{http.MethodGet, "/api/v1/metrics?resolution=x", "", http.StatusBadRequest, codeInvalidField},
+		{http.MethodGet, "/api/v1/events", "", http.StatusNotFound, codeNotFound},
+		{http.MethodPost, "/api/v1/worlds", `{"name":"a","w":0}`, http.StatusBadRequest, codeInvalidField},
+		{http.MethodPost, "/api/v1/worlds/default/explode", "", http.StatusNotFound, codeNotFound},
+		{http.MethodPost, "/api/v1/commands", `{"type":"kill_agent","id":99999}`, http.StatusNotFound, codeNotFound},
+		{http.MethodPatch, "/api/v1/agents/1", `{"nope":1}`, http.StatusBadRequest, codeInvalidField},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			status, body := doJSON(t, h, tt.method, tt.path, "", tt.body)
+			if status != tt.status || errorCode(body) != tt.code || body["type"] != "reply" || body["ok"] != false {
+				t.Fatalf("got %d %v, want %d with error code %q", status, body, tt.status, tt.code)
+			}
+		})
+	}
+}
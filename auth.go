@@ -0,0 +1,241 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	codeUnauthorized = "unauthorized"
+	codeForbidden    = "forbidden"
+	codeRateLimited  = "rate_limited"
+)
+
+type role int
+
+const (
+	roleViewer role = iota
+	roleOperator
+	roleAdmin
+)
+
+var roleNames = map[string]role{"viewer": roleViewer, "operator": roleOperator, "admin": roleAdmin}
+
+func (r role) String() string {
+	for name, v := range roleNames {
+		if v == r {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+func parseRole(v string) (role, error) {
+	r, ok := roleNames[strings.ToLower(strings.TrimSpace(v))]
+	if !ok {
+		return 0, fmt.Errorf("unknown role %q", v)
+	}
+	return r, nil
+}
+
+var commandRoles = map[string]role{
+	"viewport":           roleViewer,
+	"subscribe":          roleViewer,
+	"events_since":       roleViewer,
+	"inspect_agent":      roleViewer,
+	"watch_agent":        roleViewer,
+	"training_status":    roleViewer,
+	"add_food":           roleOperator,
+	"add_agent":          roleOperator,
+	"toggle_random_food": roleOperator,
+	"teleport_agent":     roleOperator,
+	"edit_agent":         roleOperator,
+	"clone_agent":        roleOperator,
+	"reset_brain":        roleOperator,
+	"copy_brain":         roleOperator,
+	"remove_food":        roleOperator,
+	"paint_food":         roleOperator,
+	"spawn_agents":       roleOperator,
+	"possess":            roleOperator,
+	"act":                roleOperator,
+	"release":            roleOperator,
+	"train_brain":        roleOperator,
+	"seed_planted":       roleOperator,
+	"export_brain":       roleOperator,
+	"load_brain":         roleOperator,
+	"delete_brain":       roleAdmin,
+	"kill_agent":         roleAdmin,
+	"clear_area":         roleAdmin,
+	"set_trait_cadence":  roleAdmin,
+	"reset":              roleAdmin,
+	"resize":             roleAdmin,
+}
+
+type authToken struct {
+	token string
+	role  role
+}
+
+type authConfig struct {
+	tokens    []authToken
+	anonymous role
+	origins   map[string]bool
+	anyOrigin bool
+	rate      float64
+	burst     float64
+}
+
+// loadAuthConfig reads the auth environment. Requests without a token are
+// viewers unless insecure is set and no tokens are configured.
+func loadAuthConfig(insecure bool) (*authConfig, error) {
+	a := &authConfig{anonymous: roleViewer, origins: map[string]bool{}, rate: 20, burst: 40}
+	if insecure {
+		a.anonymous = roleAdmin
+	}
+	if v := os.Getenv("AUTH_TOKENS"); v != "" {
+		for _, entry := range strings.Split(v, ",") {
+			parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return nil, fmt.Errorf("AUTH_TOKENS: expected token:role, got %q", entry)
+			}
+			r, err := parseRole(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("AUTH_TOKENS: %v", err)
+			}
+			a.tokens = append(a.tokens, authToken{token: parts[0], role: r})
+		}
+		a.anonymous = roleViewer
+	}
+	if v := os.Getenv("AUTH_ANONYMOUS_ROLE"); v != "" {
+		r, err := parseRole(v)
+		if err != nil {
+			return nil, fmt.Errorf("AUTH_ANONYMOUS_ROLE: %v", err)
+		}
+		a.anonymous = r
+	}
+	for _, o := range strings.Split(os.Getenv("ALLOWED_ORIGINS"), ",") {
+		o = strings.TrimSpace(o)
+		switch o {
+		case "":
+		case "*":
+			a.anyOrigin = true
+		default:
+			a.origins[strings.ToLower(strings.TrimSuffix(o, "/"))] = true
+		}
+	}
+	if v := os.Getenv("COMMAND_RATE"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("COMMAND_RATE: invalid value %q", v)
+		}
+		a.rate = rate
+		a.burst = 2 * rate
+		if a.burst < 1 {
+			a.burst = 1
+		}
+	}
+	return a, nil
+}
+
+func requestToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer ")
+	}
+	return r.URL.Query().Get("token")
+}
+
+func (a *authConfig) roleFor(r *http.Request) (role, error) {
+	token := requestToken(r)
+	if token == "" {
+		return a.anonymous, nil
+	}
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(t.token), []byte(token)) == 1 {
+			return t.role, nil
+		}
+	}
+	return 0, &replyError{Code: codeUnauthorized, Message: "invalid token"}
+}
+
+func (a *authConfig) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || a.anyOrigin {
+		return true
+	}
+	if a.origins[strings.ToLower(origin)] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func (a *authConfig) newLimiter() *rateLimiter {
+	if a.rate == 0 {
+		return nil
+	}
+	return &rateLimiter{rate: a.rate, burst: a.burst, tokens: a.burst, last: time.Now()}
+}
+
+func checkRole(have, need role, op string) error {
+	if have >= need {
+		return nil
+	}
+	return &replyError{Code: codeForbidden, Message: fmt.Sprintf("%s requires role %s, connection has %s", op, need, have)}
+}
+
+// authorize denies commands without an entry in commandRoles, so a handler
+// added without a role is unreachable rather than open to viewers.
+func authorize(r role, command string) error {
+	need, ok := commandRoles[command]
+	if !ok {
+		return &replyError{Code: codeUnknownCommand, Message: "unknown command type " + command}
+	}
+	return checkRole(r, need, command)
+}
+
+// authorizeCommand is authorize plus roles that depend on a command's fields.
+// Overwriting a library brain destroys the old entry, so it needs the role
+// that may delete one.
+func authorizeCommand(r role, h commandHeader, raw []byte) error {
+	if err := authorize(r, h.Type); err != nil {
+		return err
+	}
+	if h.Type == "export_brain" {
+		var c struct {
+			Overwrite bool `json:"overwrite"`
+		}
+		if json.Unmarshal(raw, &c) == nil && c.Overwrite {
+			return checkRole(r, commandRoles["delete_brain"], "export_brain with overwrite")
+		}
+	}
+	return nil
+}
+
+type rateLimiter struct {
+	rate, burst float64
+	tokens      float64
+	last        time.Time
+}
+
+func (l *rateLimiter) allow(now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+func TestEveryCommandHasRole(t *testing.T) {
+	handled := map[string]bool{}
+	for name := range simCommands {
+		handled[name] = true
+	}
+	for _, name := range connCommands {
+		handled[name] = true
+	}
+	for name := range handled {
+		if _, ok := commandRoles[name]; !ok {
+			t.Errorf("command %q has a handler but no entry in commandRoles", name)
+		}
+	}
+	for name := range commandRoles {
+		if !handled[name] {
+			t.Errorf("commandRoles has %q but nothing handles it", name)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		role    role
+		command string
+		code    string
+	}{
+		{roleViewer, "viewport", ""},
+		{roleViewer, "inspect_agent", ""},
+		{roleViewer, "add_food", codeForbidden},
+		{roleOperator, "add_food", ""},
+		{roleOperator, "kill_agent", codeForbidden},
+		{roleAdmin, "kill_agent", ""},
+		{roleAdmin, "no_such_command", codeUnknownCommand},
+		{roleViewer, "no_such_command", codeUnknownCommand},
+		{roleAdmin, "", codeUnknownCommand},
+	}
+	for _, tt := range tests {
+		err := authorize(tt.role, tt.command)
+		var re *replyError
+		code := ""
+		if errors.As(err, &re) {
+			code = re.Code
+		} else if err != nil {
+			t.Fatalf("authorize(%s, %q) = %v, not a replyError", tt.role, tt.command, err)
+		}
+		if code != tt.code {
+			t.Errorf("authorize(%s, %q) code = %q, want %q", tt.role, tt.command, code, tt.code)
+		}
+	}
+}
+
+func TestExecuteUnknownCommand(t *testing.T) {
+	cfg := sim.DefaultConfig()
+	cfg.Seed, cfg.Width, cfg.Height, cfg.InitialAgents = 1, 20, 20, 5
+	s := sim.NewSimWithConfig(cfg)
+	c := &Client{id: 1, role: roleAdmin}
+	rep := c.execute(s, []byte(`{"type":"no_such_command"}`))
+	if rep.OK || rep.Error.Code != codeUnknownCommand {
+		t.Fatalf("reply = %+v, want unknown_command", rep)
+	}
+	for name := range commandRoles {
+		rep := c.execute(s, []byte(`{"type":"`+name+`"}`))
+		if rep.Error != nil && rep.Error.Code == codeUnknownCommand {
+			t.Errorf("%s: admin got unknown_command", name)
+		}
+	}
+}
+
+func TestRESTCommandRoles(t *testing.T) {
+	auth := &authConfig{anonymous: roleViewer, tokens: []authToken{{token: "op", role: roleOperator}}}
+	h, _ := newTestAPI(t, auth)
+	tests := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+		code   string
+	}{
+		{"viewer reads", "", `{"type":"inspect_agent","id":1}`, http.StatusOK, ""},
+		{"viewer writes", "", `{"type":"add_food","x":1,"y":1}`, http.StatusForbidden, codeForbidden},
+		{"operator writes", "op", `{"type":"add_food","x":1,"y":1}`, http.StatusOK, ""},
+		{"operator kills", "op", `{"type":"kill_agent","id":1}`, http.StatusForbidden, codeForbidden},
+		{"unknown command", "op", `{"type":"no_such_command"}`, http.StatusBadRequest, codeUnknownCommand},
+		{"bad token", "nope", `{"type":"inspect_agent","id":1}`, http.StatusUnauthorized, codeUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := doJSON(t, h, http.MethodPost, "/api/v1/commands", tt.token, tt.body)
+			if status != tt.status || errorCode(body) != tt.code {
+				t.Fatalf("got %d %v, want %d %q", status, body, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestLoadAuthConfigAnonymousRole(t *testing.T) {
+	tests := []struct {
+		name      string
+		tokens    string
+		anonymous string
+		insecure  bool
+		want      role
+	}{
+		{"default", "", "", false, roleViewer},
+		{"insecure", "", "", true, roleAdmin},
+		{"insecure with tokens", "t:admin", "", true, roleViewer},
+		{"explicit", "", "operator", false, roleOperator},
+		{"explicit overrides insecure", "", "viewer", true, roleViewer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AUTH_TOKENS", tt.tokens)
+			t.Setenv("AUTH_ANONYMOUS_ROLE", tt.anonymous)
+			a, err := loadAuthConfig(tt.insecure)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.anonymous != tt.want {
+				t.Fatalf("anonymous = %s, want %s", a.anonymous, tt.want)
+			}
+		})
+	}
+}
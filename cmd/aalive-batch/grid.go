@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+type param struct {
+	name   string
+	values []json.RawMessage
+}
+
+type runSpec struct {
+	ID     int
+	Seed   int64
+	Params map[string]string
+	Config sim.Config
+}
+
+func parseGrid(v string) ([]param, error) {
+	var out []param
+	for _, part := range strings.Split(v, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("grid: expected name=v1,v2 got %q", part)
+		}
+		p := param{name: strings.TrimSpace(kv[0])}
+		for _, val := range strings.Split(kv[1], ",") {
+			p.values = append(p.values, json.RawMessage(strings.TrimSpace(val)))
+		}
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out, nil
+}
+
+func parseSeeds(v string) ([]int64, error) {
+	var out []int64
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if lo, hi, ok := strings.Cut(part, "-"); ok {
+			a, err1 := strconv.ParseInt(lo, 10, 64)
+			b, err2 := strconv.ParseInt(hi, 10, 64)
+			if err1 != nil || err2 != nil || a > b || a == 0 {
+				return nil, fmt.Errorf("seeds: invalid range %q", part)
+			}
+			for s := a; s <= b; s++ {
+				out = append(out, s)
+			}
+			continue
+		}
+		s, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || s == 0 {
+			return nil, fmt.Errorf("seeds: invalid seed %q", part)
+		}
+		out = append(out, s)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("seeds: none given")
+	}
+	return out, nil
+}
+
+func withParam(base sim.Config, name string, value json.RawMessage) (sim.Config, error) {
+	raw, err := json.Marshal(base)
+	if err != nil {
+		return base, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return base, err
+	}
+	if _, ok := fields[name]; !ok {
+		return base, fmt.Errorf("grid: unknown config field %q", name)
+	}
+	fields[name] = value
+	if raw, err = json.Marshal(fields); err != nil {
+		return base, err
+	}
+	var out sim.Config
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return base, fmt.Errorf("grid: %s=%s: %v", name, value, err)
+	}
+	return out, nil
+}
+
+func expand(base sim.Config, grid []param, seeds []int64) ([]runSpec, error) {
+	type point struct {
+		cfg    sim.Config
+		params map[string]string
+	}
+	points := []point{{cfg: base, params: map[string]string{}}}
+	for _, p := range grid {
+		next := make([]point, 0, len(points)*len(p.values))
+		for _, pt := range points {
+			for _, v := range p.values {
+				cfg, err := withParam(pt.cfg, p.name, v)
+				if err != nil {
+					return nil, err
+				}
+				params := make(map[string]string, len(pt.params)+1)
+				for k, val := range pt.params {
+					params[k] = val
+				}
+				params[p.name] = string(v)
+				next = append(next, point{cfg: cfg, params: params})
+			}
+		}
+		points = next
+	}
+	var runs []runSpec
+	for _, pt := range points {
+		for _, seed := range seeds {
+			cfg := pt.cfg
+			cfg.Seed = seed
+			cfg.Paused = false
+			if err := cfg.Validate(); err != nil {
+				return nil, fmt.Errorf("%v: %v", pt.params, err)
+			}
+			runs = append(runs, runSpec{ID: len(runs) + 1, Seed: seed, Params: pt.params, Config: cfg})
+		}
+	}
+	return runs, nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+type summary struct {
+	ID             int               `json:"id"`
+	Seed           int64             `json:"seed"`
+	Params         map[string]string `json:"params"`
+	Ticks          int               `json:"ticks"`
+	ExtinctAt      int               `json:"extinct_at,omitempty"`
+	Population     int               `json:"population"`
+	Foods          int               `json:"foods"`
+	MaxPopulation  float64           `json:"max_population"`
+	MeanPopulation float64           `json:"mean_population"`
+	Births         int               `json:"births"`
+	Deaths         int               `json:"deaths"`
+	MeanAggression float64           `json:"mean_aggression"`
+	MeanStrength   float64           `json:"mean_strength"`
+	WallSeconds    float64           `json:"wall_seconds"`
+}
+
+type options struct {
+	ticks      int
+	resolution int
+	stopEmpty  bool
+	outDir     string
+	format     string
+}
+
+func main() {
+	base := sim.DefaultConfig()
+	var (
+		configPath = flag.String("config", "", "JSON file with the base world config (same fields as the server's \"world\" block)")
+		gridFlag   = flag.String("grid", "", "parameter grid, e.g. \"random_food_prob=0.02,0.04;max_age=0,500\"")
+		seedsFlag  = flag.String("seeds", "1-4", "seeds as a list or ranges, e.g. \"1,2,10-20\"")
+		parallel   = flag.Int("parallel", runtime.NumCPU(), "runs to execute at once")
+		opts       options
+	)
+	flag.IntVar(&opts.ticks, "ticks", 2000, "ticks per run")
+	flag.IntVar(&opts.resolution, "resolution", 10, "ticks per metrics sample (1, 10 or 100)")
+	flag.BoolVar(&opts.stopEmpty, "stop-empty", true, "stop a run once the population is extinct")
+	flag.StringVar(&opts.outDir, "out", "batch-out", "output directory")
+	flag.StringVar(&opts.format, "format", "csv", "output format: csv or json")
+	flag.Parse()
+
+	if *configPath != "" {
+		var err error
+		if base, err = loadConfig(*configPath, base); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := opts.validate(); err != nil {
+		log.Fatal(err)
+	}
+	if *parallel < 1 {
+		*parallel = 1
+	}
+	grid, err := parseGrid(*gridFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	seeds, err := parseSeeds(*seedsFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	runs, err := expand(base, grid, seeds)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(opts.outDir, "runs"), 0o755); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%d runs (%d grid points x %d seeds), %d ticks each, %d in parallel", len(runs), len(runs)/len(seeds), len(seeds), opts.ticks, *parallel)
+
+	start := time.Now()
+	sums := runAll(runs, *parallel, opts)
+	if err := writeSummaries(opts, grid, sums); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("finished in %s, results in %s", time.Since(start).Round(time.Millisecond), opts.outDir)
+}
+
+// runAll executes runs on parallel workers and returns their summaries in run
+// order. Failed runs are logged and left out.
+func runAll(runs []runSpec, parallel int, opts options) []summary {
+	jobs := make(chan runSpec)
+	results := make(chan summary)
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				sum, err := execute(r, opts)
+				if err != nil {
+					log.Printf("run %d: %v", r.ID, err)
+					continue
+				}
+				results <- sum
+			}
+		}()
+	}
+	go func() {
+		for _, r := range runs {
+			jobs <- r
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	var sums []summary
+	for s := range results {
+		sums = append(sums, s)
+		log.Printf("run %d/%d done: seed=%d %v ticks=%d population=%d", len(sums), len(runs), s.Seed, s.Params, s.Ticks, s.Population)
+	}
+	sort.Slice(sums, func(i, j int) bool { return sums[i].ID < sums[j].ID })
+	return sums
+}
+
+// loadConfig reads a world config over base. Unknown fields are rejected, as
+// in the server's "world" block, so a typo cannot leave a sweep on defaults.
+func loadConfig(path string, base sim.Config) (sim.Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return base, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&base); err != nil {
+		return base, fmt.Errorf("%s: %v", path, err)
+	}
+	return base, nil
+}
+
+func (o options) validate() error {
+	switch {
+	case o.ticks <= 0:
+		return fmt.Errorf("ticks must be positive, got %d", o.ticks)
+	case o.format != "csv" && o.format != "json":
+		return fmt.Errorf("format must be csv or json")
+	case !validResolution(o.resolution):
+		return fmt.Errorf("resolution must be one of %v", sim.Resolutions)
+	}
+	return nil
+}
+
+func validResolution(r int) bool {
+	for _, v := range sim.Resolutions {
+		if v == r {
+			return true
+		}
+	}
+	return false
+}
+
+func execute(r runSpec, opts options) (summary, error) {
+	started := time.Now()
+	s := sim.NewSimWithConfig(r.Config)
+	sum := summary{ID: r.ID, Seed: r.Seed, Params: r.Params}
+	var samples []sim.MetricsSample
+	collect := func() error {
+		from := 1
+		if len(samples) > 0 {
+			from = samples[len(samples)-1].Tick + 1
+		}
+		got, err := s.MetricsHistory(from, 0, opts.resolution)
+		samples = append(samples, got...)
+		return err
+	}
+	for t := 1; t <= opts.ticks; t++ {
+		s.Tick()
+		select {
+		case <-s.StateChan:
+		default:
+		}
+		sum.Ticks = t
+		if t%(opts.resolution*100) == 0 {
+			if err := collect(); err != nil {
+				return sum, err
+			}
+		}
+		if opts.stopEmpty && s.Telemetry().Population == 0 {
+			sum.ExtinctAt = t
+			break
+		}
+	}
+	if err := collect(); err != nil {
+		return sum, err
+	}
+
+	tel := s.Telemetry()
+	sum.Population = tel.Population
+	sum.Foods = tel.Foods
+	sum.Births = tel.Births
+	sum.Deaths = tel.Deaths
+	var weighted float64
+	for _, m := range samples {
+		if m.Population > sum.MaxPopulation {
+			sum.MaxPopulation = m.Population
+		}
+		sum.MeanPopulation += m.Population
+		sum.MeanAggression += m.AvgAggression * m.Population
+		sum.MeanStrength += m.AvgStrength * m.Population
+		weighted += m.Population
+	}
+	if len(samples) > 0 {
+		sum.MeanPopulation /= float64(len(samples))
+	}
+	if weighted > 0 {
+		sum.MeanAggression /= weighted
+		sum.MeanStrength /= weighted
+	}
+	sum.WallSeconds = time.Since(started).Seconds()
+	return sum, writeSeries(opts, r.ID, samples)
+}
+
+func writeSeries(opts options, id int, samples []sim.MetricsSample) error {
+	path := filepath.Join(opts.outDir, "runs", fmt.Sprintf("%04d.%s", id, opts.format))
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if opts.format == "json" {
+		return json.NewEncoder(f).Encode(samples)
+	}
+	return sim.WriteMetricsCSV(f, samples)
+}
+
+func writeSummaries(opts options, grid []param, sums []summary) error {
+	path := filepath.Join(opts.outDir, "summary."+opts.format)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if opts.format == "json" {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sums)
+	}
+	cw := csv.NewWriter(f)
+	header := []string{"id", "seed"}
+	for _, p := range grid {
+		header = append(header, p.name)
+	}
+	header = append(header, "ticks", "extinct_at", "population", "foods", "max_population", "mean_population", "births", "deaths", "mean_aggression", "mean_strength", "wall_seconds")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	fl := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+	for _, s := range sums {
+		row := []string{strconv.Itoa(s.ID), strconv.FormatInt(s.Seed, 10)}
+		for _, p := range grid {
+			row = append(row, s.Params[p.name])
+		}
+		row = append(row,
+			strconv.Itoa(s.Ticks), strconv.Itoa(s.ExtinctAt), strconv.Itoa(s.Population), strconv.Itoa(s.Foods),
+			fl(s.MaxPopulation), fl(s.MeanPopulation), strconv.Itoa(s.Births), strconv.Itoa(s.Deaths),
+			fl(s.MeanAggression), fl(s.MeanStrength), fl(s.WallSeconds),
+		)
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
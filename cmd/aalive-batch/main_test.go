@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+func TestParseSeeds(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []int64
+		wantErr bool
+	}{
+		{"1-3", []int64{1, 2, 3}, false},
+		{"5, 2,10-11", []int64{5, 2, 10, 11}, false},
+		{"0", nil, true},
+		{"3-1", nil, true},
+		{"x", nil, true},
+		{"", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSeeds(tt.in)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSeeds(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	grid, err := parseGrid("max_age=0,500; random_food_prob=0.02,0.04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, err := expand(sim.DefaultConfig(), grid, []int64{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 8 {
+		t.Fatalf("got %d runs, want 8", len(runs))
+	}
+	last := runs[7]
+	if last.Seed != 2 || last.Config.MaxAge != 500 || last.Config.RandomFoodProb != 0.04 || last.Params["max_age"] != "500" {
+		t.Fatalf("last run = %+v", last)
+	}
+	if _, err := parseGrid("nonsense"); err == nil {
+		t.Fatal("parseGrid accepted a part without =")
+	}
+	bad, _ := parseGrid("no_such_field=1")
+	if _, err := expand(sim.DefaultConfig(), bad, []int64{1}); err == nil {
+		t.Fatal("expand accepted an unknown config field")
+	}
+}
+
+func runBatch(t *testing.T, dir string) {
+	t.Helper()
+	base := sim.DefaultConfig()
+	base.Width, base.Height, base.InitialAgents = 40, 40, 20
+	grid, err := parseGrid("random_food_prob=0.02,0.05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, err := expand(base, grid, []int64{3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := options{ticks: 150, resolution: 10, stopEmpty: true, outDir: dir, format: "csv"}
+	if err := os.MkdirAll(filepath.Join(dir, "runs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sums := runAll(runs, 3, opts)
+	if len(sums) != len(runs) {
+		t.Fatalf("%d of %d runs finished", len(sums), len(runs))
+	}
+	if err := writeSummaries(opts, grid, sums); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestBatchReproducible runs the same sweep twice and expects identical
+// output apart from wall time.
+func TestBatchReproducible(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	a, b := t.TempDir(), t.TempDir()
+	runBatch(t, a)
+	runBatch(t, b)
+
+	series, err := filepath.Glob(filepath.Join(a, "runs", "*.csv"))
+	if err != nil || len(series) != 4 {
+		t.Fatalf("series files: %v %v", series, err)
+	}
+	for _, pa := range series {
+		pb := filepath.Join(b, "runs", filepath.Base(pa))
+		if !bytes.Equal(readFile(t, pa), readFile(t, pb)) {
+			t.Errorf("%s differs between runs", filepath.Base(pa))
+		}
+	}
+
+	sa, sb := readSummary(t, a), readSummary(t, b)
+	if !reflect.DeepEqual(sa, sb) {
+		t.Fatalf("summaries differ:\n%v\n%v", sa, sb)
+	}
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func readSummary(t *testing.T, dir string) [][]string {
+	t.Helper()
+	rows, err := csv.NewReader(bytes.NewReader(readFile(t, filepath.Join(dir, "summary.csv")))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wall := len(rows[0]) - 1
+	if rows[0][wall] != "wall_seconds" {
+		t.Fatalf("unexpected header %v", rows[0])
+	}
+	for i := range rows {
+		rows[i] = rows[i][:wall]
+	}
+	return rows
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+		want    func(sim.Config) bool
+	}{
+		{"override", `{"max_age":300,"w":40}`, false, func(c sim.Config) bool { return c.MaxAge == 300 && c.Width == 40 && c.Height == 100 }},
+		{"typo", `{"max_agee":300}`, true, nil},
+		{"wrong type", `{"max_age":"old"}`, true, nil},
+		{"not json", `max_age=300`, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "world.json")
+			if err := os.WriteFile(path, []byte(tt.body), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := loadConfig(path, sim.DefaultConfig())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig = %+v, %v; want error %v", got, err, tt.wantErr)
+			}
+			if tt.want != nil && !tt.want(got) {
+				t.Fatalf("loadConfig = %+v", got)
+			}
+		})
+	}
+	if _, err := loadConfig(filepath.Join(t.TempDir(), "missing.json"), sim.DefaultConfig()); err == nil {
+		t.Fatal("loadConfig accepted a missing file")
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	ok := options{ticks: 100, resolution: 10, format: "csv"}
+	tests := []struct {
+		name    string
+		edit    func(*options)
+		wantErr bool
+	}{
+		{"defaults", func(*options) {}, false},
+		{"json", func(o *options) { o.format = "json" }, false},
+		{"zero ticks", func(o *options) { o.ticks = 0 }, true},
+		{"negative ticks", func(o *options) { o.ticks = -5 }, true},
+		{"bad format", func(o *options) { o.format = "xml" }, true},
+		{"bad resolution", func(o *options) { o.resolution = 7 }, true},
+	}
+	for _, tt := range tests {
+		o := ok
+		tt.edit(&o)
+		if err := o.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validate = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
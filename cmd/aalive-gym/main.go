@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"sort"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+const maxLine = 4 << 20
+
+type request struct {
+	Op       string          `json:"op"`
+	Config   json.RawMessage `json:"config"`
+	Control  int             `json:"control"`
+	MaxTicks int             `json:"max_ticks"`
+	Actions  map[int]int     `json:"actions"`
+}
+
+type response struct {
+	OK          bool                   `json:"ok"`
+	Error       string                 `json:"error,omitempty"`
+	Tick        int                    `json:"tick"`
+	Agents      []int                  `json:"agents,omitempty"`
+	Obs         map[int][]float64      `json:"obs,omitempty"`
+	Transitions map[int]sim.Transition `json:"transitions,omitempty"`
+	Done        bool                   `json:"done"`
+	Truncated   bool                   `json:"truncated"`
+	Population  int                    `json:"population"`
+	Features    []string               `json:"features,omitempty"`
+	Actions     int                    `json:"actions,omitempty"`
+}
+
+type env struct {
+	base     sim.Config
+	sim      *sim.Sim
+	tick     int
+	maxTicks int
+}
+
+var errNoEpisode = errors.New("call reset first")
+
+func main() {
+	var (
+		addr       = flag.String("addr", "127.0.0.1:5555", "TCP address to listen on")
+		unixPath   = flag.String("unix", "", "listen on this unix socket instead of TCP")
+		configPath = flag.String("config", "", "JSON file with the base world config")
+	)
+	flag.Parse()
+
+	base := sim.DefaultConfig()
+	base.Paused = false
+	if *configPath != "" {
+		raw, err := os.ReadFile(*configPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := json.Unmarshal(raw, &base); err != nil {
+			log.Fatalf("%s: %v", *configPath, err)
+		}
+	}
+	if err := base.Validate(); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	network, address := "tcp", *addr
+	if *unixPath != "" {
+		network, address = "unix", *unixPath
+		_ = os.Remove(address)
+	}
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("gym env listening on %s %s", network, address)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go serve(conn, base)
+	}
+}
+
+func serve(conn net.Conn, base sim.Config) {
+	defer conn.Close()
+	e := &env{base: base}
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 64<<10), maxLine)
+	enc := json.NewEncoder(conn)
+	for sc.Scan() {
+		var req request
+		var resp response
+		if err := json.Unmarshal(sc.Bytes(), &req); err != nil {
+			resp = response{Error: err.Error()}
+		} else if req.Op == "close" {
+			return
+		} else {
+			resp = e.handle(req)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (e *env) handle(req request) response {
+	var (
+		resp response
+		err  error
+	)
+	switch req.Op {
+	case "reset":
+		resp, err = e.reset(req)
+	case "step":
+		resp, err = e.step(req.Actions)
+	default:
+		err = errors.New("unknown op " + req.Op + " (want reset, step or close)")
+	}
+	if err != nil {
+		return response{Error: err.Error()}
+	}
+	resp.OK = true
+	return resp
+}
+
+func (e *env) reset(req request) (response, error) {
+	cfg := e.base
+	if len(req.Config) > 0 {
+		if err := json.Unmarshal(req.Config, &cfg); err != nil {
+			return response{}, err
+		}
+	}
+	cfg.Paused = false
+	if err := cfg.Validate(); err != nil {
+		return response{}, err
+	}
+	n := req.Control
+	if n == 0 {
+		n = 1
+	}
+	if n < 0 || n > cfg.InitialAgents {
+		return response{}, errors.New("control must be between 1 and initial_agents")
+	}
+	s := sim.NewSimWithConfig(cfg)
+	agents := s.Agents()
+	ids := make([]int, 0, len(agents))
+	for _, a := range agents {
+		ids = append(ids, a.ID)
+	}
+	sort.Ints(ids)
+	ids = ids[:n]
+	if err := s.Control(ids...); err != nil {
+		return response{}, err
+	}
+	e.sim, e.tick, e.maxTicks = s, 0, req.MaxTicks
+
+	resp := response{Agents: ids, Obs: make(map[int][]float64, n), Population: len(agents), Features: sim.FeatureNames, Actions: sim.NumActions}
+	for _, id := range ids {
+		resp.Obs[id], _ = s.Observe(id)
+	}
+	return resp, nil
+}
+
+func (e *env) step(actions map[int]int) (response, error) {
+	if e.sim == nil {
+		return response{}, errNoEpisode
+	}
+	ts, err := e.sim.Step(actions)
+	if err != nil {
+		return response{}, err
+	}
+	select {
+	case <-e.sim.StateChan:
+	default:
+	}
+	e.tick++
+	resp := response{Tick: e.tick, Transitions: ts, Population: len(e.sim.Agents())}
+	resp.Done = len(e.sim.Controlled()) == 0
+	resp.Truncated = !resp.Done && e.maxTicks > 0 && e.tick >= e.maxTicks
+	if resp.Done || resp.Truncated {
+		e.sim = nil
+	}
+	return resp, nil
+}
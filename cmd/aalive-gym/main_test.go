@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+type gymClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialGym(t *testing.T) *gymClient {
+	t.Helper()
+	client, server := net.Pipe()
+	base := sim.DefaultConfig()
+	base.Paused = false
+	go serve(server, base)
+	t.Cleanup(func() { client.Close() })
+	_ = client.SetDeadline(time.Now().Add(10 * time.Second))
+	return &gymClient{conn: client, r: bufio.NewReader(client)}
+}
+
+func (c *gymClient) send(t *testing.T, line string) response {
+	t.Helper()
+	if _, err := io.WriteString(c.conn, line+"\n"); err != nil {
+		t.Fatalf("write %s: %v", line, err)
+	}
+	raw, err := c.r.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("read reply to %s: %v", line, err)
+	}
+	var resp response
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		t.Fatalf("reply to %s: %v", line, err)
+	}
+	return resp
+}
+
+func TestProtocolErrors(t *testing.T) {
+	c := dialGym(t)
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"step before reset", `{"op":"step"}`, errNoEpisode.Error()},
+		{"unknown op", `{"op":"jump"}`, "unknown op jump"},
+		{"not json", `nope`, "invalid character"},
+		{"too many controlled", `{"op":"reset","config":{"initial_agents":2},"control":3}`, "control must be"},
+		{"invalid config", `{"op":"reset","config":{"w":0}}`, "w must be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := c.send(t, tt.line)
+			if resp.OK || !strings.Contains(resp.Error, tt.want) {
+				t.Fatalf("reply = %+v, want error containing %q", resp, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtocolEpisode(t *testing.T) {
+	c := dialGym(t)
+	reset := c.send(t, `{"op":"reset","config":{"w":30,"h":30,"initial_agents":3,"seed":1,"random_food":false},"control":2,"max_ticks":2}`)
+	if !reset.OK || len(reset.Agents) != 2 || reset.Population != 3 || reset.Actions != sim.NumActions || len(reset.Features) != len(sim.FeatureNames) {
+		t.Fatalf("reset = %+v", reset)
+	}
+	for _, id := range reset.Agents {
+		if len(reset.Obs[id]) != len(sim.FeatureNames) {
+			t.Fatalf("obs for %d = %v", id, reset.Obs[id])
+		}
+	}
+	a, b := reset.Agents[0], reset.Agents[1]
+
+	if resp := c.send(t, fmt.Sprintf(`{"op":"step","actions":{"%d":%d}}`, a, sim.NumActions)); resp.OK {
+		t.Fatalf("invalid action accepted: %+v", resp)
+	}
+
+	first := c.send(t, fmt.Sprintf(`{"op":"step","actions":{"%d":4}}`, a))
+	if !first.OK || first.Tick != 1 || first.Done || first.Truncated || len(first.Transitions) != 2 {
+		t.Fatalf("first step = %+v", first)
+	}
+	if info := first.Transitions[a].Info; !info.External || info.Action != 4 {
+		t.Fatalf("driven agent info = %+v", info)
+	}
+	if info := first.Transitions[b].Info; info.External {
+		t.Fatalf("agent without an action was driven: %+v", info)
+	}
+
+	last := c.send(t, `{"op":"step"}`)
+	if !last.OK || last.Tick != 2 || last.Done || !last.Truncated {
+		t.Fatalf("step at max_ticks = %+v", last)
+	}
+	if resp := c.send(t, `{"op":"step"}`); resp.OK || resp.Error != errNoEpisode.Error() {
+		t.Fatalf("step after truncation = %+v", resp)
+	}
+
+	if again := c.send(t, `{"op":"reset","config":{"w":30,"h":30,"initial_agents":3,"seed":1,"random_food":false},"control":2}`); !again.OK || again.Agents[0] != a {
+		t.Fatalf("second reset = %+v", again)
+	}
+
+	if _, err := io.WriteString(c.conn, `{"op":"close"}`+"\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.r.ReadBytes('\n'); err != io.EOF {
+		t.Fatalf("read after close = %v, want EOF", err)
+	}
+}
+
+func TestStepDone(t *testing.T) {
+	s := sim.NewSimWithConfig(sim.Config{Width: 10, Height: 10, TickMillis: 100, InitialAgents: 0})
+	id, err := s.AddAgentAt(5, 5, 0.05, sim.Female, 0.5, 1, 10, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Control(id); err != nil {
+		t.Fatal(err)
+	}
+	e := &env{sim: s}
+	resp, err := e.step(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr := resp.Transitions[id]; !resp.Done || resp.Truncated || !tr.Done || tr.Info.Cause != sim.CauseStarved {
+		t.Fatalf("step after the last controlled agent died = %+v", resp)
+	}
+	if e.sim != nil {
+		t.Fatal("episode still open after done")
+	}
+}
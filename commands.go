@@ -0,0 +1,1025 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+const (
+	codeBadRequest     = "bad_request"
+	codeUnknownCommand = "unknown_command"
+	codeInvalidField   = "invalid_field"
+	codeMissingField   = "missing_field"
+	codeOutOfBounds    = "out_of_bounds"
+	codeCellOccupied   = "cell_occupied"
+	codeInvalidSex     = "invalid_sex"
+	codeNotFound       = "not_found"
+	codeInternal       = "internal"
+
+	codeMethodNotAllowed = "method_not_allowed"
+	codeTooLarge         = "too_large"
+	codeUnavailable      = "unavailable"
+)
+
+var errAgentID = fieldError(codeInvalidField, "id", "must be an integer")
+
+type commandHeader struct {
+	Type      string `json:"type"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+type replyError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *replyError) Error() string { return e.Code + ": " + e.Message }
+
+type reply struct {
+	Type      string      `json:"type"`
+	RequestID string      `json:"request_id,omitempty"`
+	Command   string      `json:"command"`
+	OK        bool        `json:"ok"`
+	ID        int         `json:"id,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	Error     *replyError `json:"error,omitempty"`
+}
+
+func (r reply) status() int {
+	if r.OK {
+		return http.StatusOK
+	}
+	switch r.Error.Code {
+	case codeUnauthorized:
+		return http.StatusUnauthorized
+	case codeForbidden:
+		return http.StatusForbidden
+	case codeRateLimited:
+		return http.StatusTooManyRequests
+	case codeNotFound:
+		return http.StatusNotFound
+	case codeCellOccupied, codeConflict:
+		return http.StatusConflict
+	case codeMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case codeTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case codeUnavailable:
+		return http.StatusServiceUnavailable
+	case codeInternal:
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+func fieldError(code, field, msg string) *replyError {
+	return &replyError{Code: code, Message: field + ": " + msg}
+}
+
+func toReplyError(err error) *replyError {
+	var re *replyError
+	switch {
+	case errors.As(err, &re):
+		return re
+	case errors.Is(err, sim.ErrOutOfBounds), errors.Is(err, sim.ErrEmptyBrush):
+		return &replyError{Code: codeOutOfBounds, Message: err.Error()}
+	case errors.Is(err, sim.ErrCellOccupied):
+		return &replyError{Code: codeCellOccupied, Message: err.Error()}
+	case errors.Is(err, sim.ErrInvalidBrush), errors.Is(err, sim.ErrInvalidArea), errors.Is(err, sim.ErrInvalidConfig):
+		return &replyError{Code: codeInvalidField, Message: err.Error()}
+	case errors.Is(err, sim.ErrInvalidSex):
+		return &replyError{Code: codeInvalidSex, Message: err.Error()}
+	case errors.Is(err, sim.ErrInvalidAction), errors.Is(err, sim.ErrBadTraining), errors.Is(err, sim.ErrInvalidBrain):
+		return &replyError{Code: codeInvalidField, Message: err.Error()}
+	case errors.Is(err, errWorldExists), errors.Is(err, errDefaultWorld), errors.Is(err, sim.ErrPossessed), errors.Is(err, sim.ErrNotControlled),
+		errors.Is(err, sim.ErrNoSamples), errors.Is(err, sim.ErrNoTrained), errors.Is(err, errBrainExists), errors.Is(err, sim.ErrTrainingBusy):
+		return &replyError{Code: codeConflict, Message: err.Error()}
+	case errors.Is(err, sim.ErrAgentNotFound), errors.Is(err, sim.ErrFoodNotFound), errors.Is(err, errWorldNotFound), errors.Is(err, errBrainNotFound):
+		return &replyError{Code: codeNotFound, Message: err.Error()}
+	}
+	return &replyError{Code: codeInternal, Message: err.Error()}
+}
+
+func decodeStrict(raw []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return &replyError{Code: codeInvalidField, Message: err.Error()}
+	}
+	return nil
+}
+
+type addFoodCmd struct {
+	commandHeader
+	X      *int     `json:"x"`
+	Y      *int     `json:"y"`
+	Energy *float64 `json:"energy"`
+}
+
+type toggleRandomFoodCmd struct {
+	commandHeader
+	Enabled *bool `json:"enabled"`
+}
+
+type addAgentCmd struct {
+	commandHeader
+	X        *int            `json:"x"`
+	Y        *int            `json:"y"`
+	Energy   *float64        `json:"energy"`
+	Sex      *string         `json:"sex"`
+	Agg      *float64        `json:"agg"`
+	Spd      *int            `json:"spd"`
+	Strength *float64        `json:"strength"`
+	Repro    *float64        `json:"repro"`
+	Brain    json.RawMessage `json:"brain"`
+}
+
+type setTraitCadenceCmd struct {
+	commandHeader
+	Every *int `json:"every"`
+}
+
+type inspectAgentCmd struct {
+	commandHeader
+	ID *int `json:"id"`
+}
+
+type agentInspection struct {
+	*sim.AgentHistory
+	Policy *sim.PolicyView `json:"policy,omitempty"`
+}
+
+type agentIDCmd struct {
+	commandHeader
+	ID *int `json:"id"`
+}
+
+type teleportAgentCmd struct {
+	commandHeader
+	ID *int `json:"id"`
+	X  *int `json:"x"`
+	Y  *int `json:"y"`
+}
+
+type editAgentCmd struct {
+	commandHeader
+	ID *int `json:"id"`
+	sim.AgentEdit
+}
+
+type copyBrainCmd struct {
+	commandHeader
+	From *int `json:"from"`
+	To   *int `json:"to"`
+}
+
+type removeFoodCmd struct {
+	commandHeader
+	X      *int      `json:"x"`
+	Y      *int      `json:"y"`
+	Region *sim.Rect `json:"region"`
+}
+
+type paintFoodCmd struct {
+	commandHeader
+	sim.Brush
+	Density *float64 `json:"density"`
+	Energy  *float64 `json:"energy"`
+}
+
+type spawnAgentsCmd struct {
+	commandHeader
+	sim.Brush
+	sim.SpawnSpec
+}
+
+type clearAreaCmd struct {
+	commandHeader
+	sim.Brush
+	Agents *bool `json:"agents"`
+	Food   *bool `json:"food"`
+}
+
+type resetCmd struct {
+	commandHeader
+	W             *int   `json:"w"`
+	H             *int   `json:"h"`
+	Seed          *int64 `json:"seed"`
+	InitialAgents *int   `json:"initial_agents"`
+}
+
+type commandFunc func(s *sim.Sim, raw []byte) (int, interface{}, error)
+
+type trainBrainCmd struct {
+	commandHeader
+	Source  *string  `json:"source"`
+	Agent   *int     `json:"agent"`
+	Since   *int     `json:"since"`
+	Samples *int     `json:"samples"`
+	Epochs  *int     `json:"epochs"`
+	LR      *float64 `json:"lr"`
+	L2      *float64 `json:"l2"`
+}
+
+type exportBrainCmd struct {
+	commandHeader
+	ID        *int    `json:"id"`
+	Trained   bool    `json:"trained"`
+	Name      *string `json:"name"`
+	Overwrite bool    `json:"overwrite"`
+}
+
+type loadBrainCmd struct {
+	commandHeader
+	ID    *int            `json:"id"`
+	Brain json.RawMessage `json:"brain"`
+}
+
+type deleteBrainCmd struct {
+	commandHeader
+	Name *string `json:"name"`
+}
+
+type seedPlantedCmd struct {
+	commandHeader
+	Enabled *bool `json:"enabled"`
+}
+
+var simCommands = map[string]commandFunc{
+	"add_food":           cmdAddFood,
+	"toggle_random_food": cmdToggleRandomFood,
+	"add_agent":          cmdAddAgent,
+	"set_trait_cadence":  cmdSetTraitCadence,
+	"inspect_agent":      cmdInspectAgent,
+	"kill_agent":         cmdKillAgent,
+	"teleport_agent":     cmdTeleportAgent,
+	"edit_agent":         cmdEditAgent,
+	"clone_agent":        cmdCloneAgent,
+	"reset_brain":        cmdResetBrain,
+	"copy_brain":         cmdCopyBrain,
+	"remove_food":        cmdRemoveFood,
+	"paint_food":         cmdPaintFood,
+	"spawn_agents":       cmdSpawnAgents,
+	"clear_area":         cmdClearArea,
+	"reset":              cmdReset,
+	"resize":             cmdResize,
+	"train_brain":        cmdTrainBrain,
+	"training_status":    cmdTrainingStatus,
+	"seed_planted":       cmdSeedPlanted,
+	"export_brain":       cmdExportBrain,
+	"load_brain":         cmdLoadBrain,
+	"delete_brain":       cmdDeleteBrain,
+}
+
+func requireXY(x, y *int) error {
+	if x == nil {
+		return fieldError(codeMissingField, "x", "required")
+	}
+	if y == nil {
+		return fieldError(codeMissingField, "y", "required")
+	}
+	return nil
+}
+
+func floatOr(v *float64, def float64) float64 {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+func checkRange(field string, v, lo, hi float64) error {
+	if v < lo || v > hi {
+		return fieldError(codeInvalidField, field, fmt.Sprintf("must be between %g and %g", lo, hi))
+	}
+	return nil
+}
+
+func cmdAddFood(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c addFoodCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	if err := requireXY(c.X, c.Y); err != nil {
+		return 0, nil, err
+	}
+	energy := floatOr(c.Energy, 12)
+	if energy <= 0 {
+		return 0, nil, fieldError(codeInvalidField, "energy", "must be positive")
+	}
+	return 0, nil, s.AddFoodAt(*c.X, *c.Y, energy)
+}
+
+func cmdToggleRandomFood(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c toggleRandomFoodCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	if c.Enabled == nil {
+		return 0, nil, fieldError(codeMissingField, "enabled", "required")
+	}
+	s.SetRandomFood(*c.Enabled)
+	return 0, nil, nil
+}
+
+func cmdAddAgent(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c addAgentCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	if err := requireXY(c.X, c.Y); err != nil {
+		return 0, nil, err
+	}
+	sex := sim.Male
+	if c.Sex != nil {
+		parsed, err := sim.ParseSex(*c.Sex)
+		if err != nil {
+			return 0, nil, err
+		}
+		sex = parsed
+	}
+	energy := floatOr(c.Energy, 40)
+	if energy <= 0 {
+		return 0, nil, fieldError(codeInvalidField, "energy", "must be positive")
+	}
+	agg := floatOr(c.Agg, 0.5)
+	if err := checkRange("agg", agg, 0, 1); err != nil {
+		return 0, nil, err
+	}
+	spd := 1
+	if c.Spd != nil {
+		spd = *c.Spd
+	}
+	if err := checkRange("spd", float64(spd), 1, 5); err != nil {
+		return 0, nil, err
+	}
+	strength := floatOr(c.Strength, 5)
+	if strength < 0 {
+		return 0, nil, fieldError(codeInvalidField, "strength", "must not be negative")
+	}
+	repro := floatOr(c.Repro, 0.05)
+	if err := checkRange("repro", repro, 0, 1); err != nil {
+		return 0, nil, err
+	}
+	if len(c.Brain) > 0 {
+		b, err := resolveBrain(c.Brain)
+		if err != nil {
+			return 0, nil, err
+		}
+		id, err := s.AddAgentWithBrain(*c.X, *c.Y, energy, sex, agg, spd, strength, repro, b)
+		return id, nil, err
+	}
+	id, err := s.AddAgentAt(*c.X, *c.Y, energy, sex, agg, spd, strength, repro)
+	return id, nil, err
+}
+
+func cmdSetTraitCadence(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c setTraitCadenceCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	if c.Every == nil {
+		return 0, nil, fieldError(codeMissingField, "every", "required")
+	}
+	if *c.Every < 0 {
+		return 0, nil, fieldError(codeInvalidField, "every", "must not be negative")
+	}
+	s.SetTraitStatsEvery(*c.Every)
+	return 0, nil, nil
+}
+
+func cmdInspectAgent(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c inspectAgentCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	if c.ID == nil {
+		return 0, nil, fieldError(codeMissingField, "id", "required")
+	}
+	h, ok := s.AgentHistory(*c.ID)
+	if !ok {
+		return 0, nil, sim.ErrAgentNotFound
+	}
+	out := agentInspection{AgentHistory: h}
+	if p, alive := s.Policy(*c.ID); alive {
+		out.Policy = &p
+	}
+	return h.ID, out, nil
+}
+
+func decodeAgentID(raw []byte) (int, error) {
+	var c agentIDCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, err
+	}
+	if c.ID == nil {
+		return 0, fieldError(codeMissingField, "id", "required")
+	}
+	return *c.ID, nil
+}
+
+func cmdKillAgent(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	id, err := decodeAgentID(raw)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := s.KillAgent(id); err != nil {
+		return 0, nil, err
+	}
+	return id, nil, nil
+}
+
+func cmdTeleportAgent(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c teleportAgentCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	if c.ID == nil {
+		return 0, nil, fieldError(codeMissingField, "id", "required")
+	}
+	if err := requireXY(c.X, c.Y); err != nil {
+		return 0, nil, err
+	}
+	if err := s.TeleportAgent(*c.ID, *c.X, *c.Y); err != nil {
+		return 0, nil, err
+	}
+	return *c.ID, nil, nil
+}
+
+func validateAgentEdit(e sim.AgentEdit) error {
+	if e.Energy != nil && *e.Energy <= 0 {
+		return fieldError(codeInvalidField, "energy", "must be positive")
+	}
+	if e.Sex != nil {
+		if _, err := sim.ParseSex(*e.Sex); err != nil {
+			return err
+		}
+	}
+	if e.Aggression != nil {
+		if err := checkRange("agg", *e.Aggression, 0, 1); err != nil {
+			return err
+		}
+	}
+	if e.Speed != nil {
+		if err := checkRange("spd", float64(*e.Speed), 1, 5); err != nil {
+			return err
+		}
+	}
+	if e.Strength != nil && *e.Strength < 0 {
+		return fieldError(codeInvalidField, "strength", "must not be negative")
+	}
+	if e.Repro != nil {
+		if err := checkRange("repro", *e.Repro, 0, 1); err != nil {
+			return err
+		}
+	}
+	if e.LearningRate != nil {
+		if err := checkRange("lr", *e.LearningRate, 0, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func editAgent(s *sim.Sim, id int, e sim.AgentEdit) (int, interface{}, error) {
+	if err := validateAgentEdit(e); err != nil {
+		return 0, nil, err
+	}
+	view, err := s.EditAgent(id, e)
+	if err != nil {
+		return 0, nil, err
+	}
+	return id, view, nil
+}
+
+func cmdEditAgent(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c editAgentCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	if c.ID == nil {
+		return 0, nil, fieldError(codeMissingField, "id", "required")
+	}
+	return editAgent(s, *c.ID, c.AgentEdit)
+}
+
+func cmdCloneAgent(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	id, err := decodeAgentID(raw)
+	if err != nil {
+		return 0, nil, err
+	}
+	clone, err := s.CloneAgent(id)
+	return clone, nil, err
+}
+
+func cmdResetBrain(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	id, err := decodeAgentID(raw)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := s.ResetBrain(id); err != nil {
+		return 0, nil, err
+	}
+	return id, nil, nil
+}
+
+func cmdCopyBrain(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c copyBrainCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	if c.From == nil {
+		return 0, nil, fieldError(codeMissingField, "from", "required")
+	}
+	if c.To == nil {
+		return 0, nil, fieldError(codeMissingField, "to", "required")
+	}
+	if err := s.CopyBrain(*c.From, *c.To); err != nil {
+		return 0, nil, err
+	}
+	return *c.To, nil, nil
+}
+
+func cmdRemoveFood(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c removeFoodCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	if c.Region != nil {
+		if c.X != nil || c.Y != nil {
+			return 0, nil, fieldError(codeInvalidField, "region", "cannot be combined with x/y")
+		}
+		if c.Region.W <= 0 || c.Region.H <= 0 {
+			return 0, nil, fieldError(codeInvalidField, "region", "w and h must be positive")
+		}
+		return 0, map[string]int{"removed": s.RemoveFoodIn(*c.Region)}, nil
+	}
+	if err := requireXY(c.X, c.Y); err != nil {
+		return 0, nil, err
+	}
+	if err := s.RemoveFoodAt(*c.X, *c.Y); err != nil {
+		return 0, nil, err
+	}
+	return 0, map[string]int{"removed": 1}, nil
+}
+
+const maxSpawnCount = 500
+
+func cmdPaintFood(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c paintFoodCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	density := floatOr(c.Density, 0.1)
+	if err := checkRange("density", density, 0, 1); err != nil {
+		return 0, nil, err
+	}
+	energy := floatOr(c.Energy, 12)
+	if energy <= 0 {
+		return 0, nil, fieldError(codeInvalidField, "energy", "must be positive")
+	}
+	n, err := s.PaintFood(c.Brush, density, energy)
+	if err != nil {
+		return 0, nil, err
+	}
+	return 0, map[string]int{"added": n}, nil
+}
+
+func checkSpawnRange(field string, r *sim.Range, lo, hi float64) error {
+	if r == nil {
+		return nil
+	}
+	if r.Min > r.Max {
+		return fieldError(codeInvalidField, field, "min must not exceed max")
+	}
+	if err := checkRange(field+".min", r.Min, lo, hi); err != nil {
+		return err
+	}
+	return checkRange(field+".max", r.Max, lo, hi)
+}
+
+func cmdSpawnAgents(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c spawnAgentsCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	if err := checkRange("count", float64(c.Count), 1, maxSpawnCount); err != nil {
+		return 0, nil, err
+	}
+	if c.Energy != nil && c.Energy.Min <= 0 {
+		return 0, nil, fieldError(codeInvalidField, "energy.min", "must be positive")
+	}
+	if c.Strength != nil && c.Strength.Min < 0 {
+		return 0, nil, fieldError(codeInvalidField, "strength.min", "must not be negative")
+	}
+	checks := []struct {
+		field  string
+		r      *sim.Range
+		lo, hi float64
+	}{
+		{"energy", c.Energy, 0, math.MaxFloat64},
+		{"agg", c.Agg, 0, 1},
+		{"spd", c.Spd, 1, 5},
+		{"strength", c.Strength, 0, math.MaxFloat64},
+		{"repro", c.Repro, 0, 1},
+	}
+	for _, ch := range checks {
+		if err := checkSpawnRange(ch.field, ch.r, ch.lo, ch.hi); err != nil {
+			return 0, nil, err
+		}
+	}
+	ids, err := s.SpawnAgents(c.Brush, c.SpawnSpec)
+	if err != nil {
+		return 0, nil, err
+	}
+	return 0, map[string][]int{"ids": ids}, nil
+}
+
+func cmdClearArea(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c clearAreaCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	agents := c.Agents == nil || *c.Agents
+	food := c.Food == nil || *c.Food
+	na, nf, err := s.ClearArea(c.Brush, agents, food)
+	if err != nil {
+		return 0, nil, err
+	}
+	return 0, map[string]int{"agents": na, "food": nf}, nil
+}
+
+func resetWorld(s *sim.Sim, c resetCmd) (int, interface{}, error) {
+	cfg := s.Config()
+	if c.W != nil {
+		cfg.Width = *c.W
+	}
+	if c.H != nil {
+		cfg.Height = *c.H
+	}
+	if c.Seed != nil {
+		cfg.Seed = *c.Seed
+	}
+	if c.InitialAgents != nil {
+		cfg.InitialAgents = *c.InitialAgents
+	}
+	cfg, err := s.Reset(cfg)
+	if err != nil {
+		return 0, nil, err
+	}
+	return 0, cfg, nil
+}
+
+func cmdReset(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c resetCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	return resetWorld(s, c)
+}
+
+func cmdResize(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c resetCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	if c.W == nil {
+		return 0, nil, fieldError(codeMissingField, "w", "required")
+	}
+	if c.H == nil {
+		return 0, nil, fieldError(codeMissingField, "h", "required")
+	}
+	return resetWorld(s, c)
+}
+
+const defaultHeuristicSamples = 2000
+
+func cmdTrainBrain(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c trainBrainCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	opts := sim.DefaultTrainOptions()
+	if c.Epochs != nil {
+		if err := checkRange("epochs", float64(*c.Epochs), 1, sim.MaxTrainEpochs); err != nil {
+			return 0, nil, err
+		}
+		opts.Epochs = *c.Epochs
+	}
+	if c.LR != nil {
+		if *c.LR <= 0 {
+			return 0, nil, fieldError(codeInvalidField, "lr", "must be positive")
+		}
+		opts.LR = *c.LR
+	}
+	if c.L2 != nil {
+		if err := checkRange("l2", *c.L2, 0, 1); err != nil {
+			return 0, nil, err
+		}
+		opts.L2 = *c.L2
+	}
+	source := "demonstrations"
+	if c.Source != nil {
+		source = *c.Source
+	}
+	var job sim.TrainJob
+	var err error
+	switch source {
+	case "demonstrations":
+		if c.Samples != nil {
+			return 0, nil, fieldError(codeInvalidField, "samples", "only used with source heuristic")
+		}
+		agent, since := 0, 0
+		if c.Agent != nil {
+			agent = *c.Agent
+		}
+		if c.Since != nil {
+			since = *c.Since
+		}
+		job, err = s.StartTraining(s.Demonstrations(agent, since), opts, source)
+	case "heuristic":
+		if c.Agent != nil || c.Since != nil {
+			return 0, nil, fieldError(codeInvalidField, "source", "agent and since only apply to demonstrations")
+		}
+		n := defaultHeuristicSamples
+		if c.Samples != nil {
+			if err := checkRange("samples", float64(*c.Samples), 1, sim.MaxTrainSamples); err != nil {
+				return 0, nil, err
+			}
+			n = *c.Samples
+		}
+		job, err = s.StartHeuristicTraining(n, opts)
+	default:
+		return 0, nil, fieldError(codeInvalidField, "source", "must be demonstrations or heuristic")
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return job.ID, job, nil
+}
+
+func cmdTrainingStatus(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c commandHeader
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	job, ok := s.TrainingJob()
+	if !ok {
+		return 0, nil, &replyError{Code: codeNotFound, Message: "no training job yet"}
+	}
+	return job.ID, job, nil
+}
+
+func cmdSeedPlanted(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c seedPlantedCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	if c.Enabled == nil {
+		return 0, nil, fieldError(codeMissingField, "enabled", "required")
+	}
+	return 0, nil, s.SeedPlanted(*c.Enabled)
+}
+
+func exportBrain(s *sim.Sim, id *int, trained bool) (brainFile, error) {
+	f := brainFile{SavedAt: time.Now().UTC(), Origin: brainOrigin{Tick: s.Telemetry().Ticks}}
+	switch {
+	case trained && id != nil:
+		return f, fieldError(codeInvalidField, "trained", "give either id or trained")
+	case trained:
+		b, ok := s.TrainedBrain()
+		if !ok {
+			return f, sim.ErrNoTrained
+		}
+		f.Brain = b
+	case id != nil:
+		b, err := s.AgentBrain(*id)
+		if err != nil {
+			return f, err
+		}
+		f.Brain, f.Origin.AgentID = b, *id
+	default:
+		return f, fieldError(codeMissingField, "id", "required (or trained)")
+	}
+	return f, nil
+}
+
+func cmdExportBrain(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c exportBrainCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	f, err := exportBrain(s, c.ID, c.Trained)
+	if err != nil {
+		return 0, nil, err
+	}
+	if c.Name != nil {
+		f.Name = *c.Name
+		if err := library.save(f, c.Overwrite); err != nil {
+			return 0, nil, err
+		}
+	}
+	return f.Origin.AgentID, f, nil
+}
+
+func cmdLoadBrain(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c loadBrainCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	if c.ID == nil {
+		return 0, nil, fieldError(codeMissingField, "id", "required")
+	}
+	if len(c.Brain) == 0 {
+		return 0, nil, fieldError(codeMissingField, "brain", "required")
+	}
+	b, err := resolveBrain(c.Brain)
+	if err != nil {
+		return 0, nil, err
+	}
+	return *c.ID, nil, s.LoadBrain(*c.ID, b)
+}
+
+func cmdDeleteBrain(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var c deleteBrainCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return 0, nil, err
+	}
+	if c.Name == nil {
+		return 0, nil, fieldError(codeMissingField, "name", "required")
+	}
+	return 0, nil, library.remove(*c.Name)
+}
+
+func parseHeader(raw []byte) (commandHeader, *replyError) {
+	var h commandHeader
+	if err := json.Unmarshal(raw, &h); err != nil {
+		return h, &replyError{Code: codeBadRequest, Message: "malformed JSON: " + err.Error()}
+	}
+	if h.Type == "" {
+		return h, fieldError(codeMissingField, "type", "required")
+	}
+	return h, nil
+}
+
+func newReply(h commandHeader, id int, data interface{}, err error) reply {
+	r := reply{Type: "reply", RequestID: h.RequestID, Command: h.Type, OK: err == nil, ID: id, Data: data}
+	if err != nil {
+		r.Error = toReplyError(err)
+		r.ID = 0
+		r.Data = nil
+	}
+	return r
+}
+
+func executeCommand(s *sim.Sim, raw []byte) reply {
+	h, herr := parseHeader(raw)
+	if herr != nil {
+		return newReply(h, 0, nil, herr)
+	}
+	fn, ok := simCommands[h.Type]
+	if !ok {
+		return newReply(h, 0, nil, &replyError{Code: codeUnknownCommand, Message: "unknown command type " + h.Type})
+	}
+	id, data, err := fn(s, raw)
+	return newReply(h, id, data, err)
+}
+
+type eventsSinceCmd struct {
+	commandHeader
+	Cursor *uint64 `json:"cursor"`
+}
+
+type actCmd struct {
+	commandHeader
+	ID     *int `json:"id"`
+	Action *int `json:"action"`
+	DX     *int `json:"dx"`
+	DY     *int `json:"dy"`
+}
+
+func (c *Client) possess(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var cmd agentIDCmd
+	if err := decodeStrict(raw, &cmd); err != nil {
+		return 0, nil, err
+	}
+	if cmd.ID == nil {
+		return 0, nil, fieldError(codeMissingField, "id", "required")
+	}
+	view, err := s.Possess(*cmd.ID, c.id)
+	if err != nil {
+		return 0, nil, err
+	}
+	return view.ID, view, nil
+}
+
+func (c *Client) act(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var cmd actCmd
+	if err := decodeStrict(raw, &cmd); err != nil {
+		return 0, nil, err
+	}
+	if cmd.ID == nil {
+		return 0, nil, fieldError(codeMissingField, "id", "required")
+	}
+	act := 0
+	switch {
+	case cmd.Action != nil && (cmd.DX != nil || cmd.DY != nil):
+		return 0, nil, fieldError(codeInvalidField, "action", "give either action or dx/dy")
+	case cmd.Action != nil:
+		act = *cmd.Action
+	case cmd.DX != nil && cmd.DY != nil:
+		if err := checkRange("dx", float64(*cmd.DX), -1, 1); err != nil {
+			return 0, nil, err
+		}
+		if err := checkRange("dy", float64(*cmd.DY), -1, 1); err != nil {
+			return 0, nil, err
+		}
+		act = (*cmd.DY+1)*3 + *cmd.DX + 1
+	default:
+		return 0, nil, fieldError(codeMissingField, "action", "required (or dx and dy)")
+	}
+	return *cmd.ID, nil, s.Act(*cmd.ID, c.id, act)
+}
+
+func (c *Client) release(s *sim.Sim, raw []byte) (int, interface{}, error) {
+	var cmd agentIDCmd
+	if err := decodeStrict(raw, &cmd); err != nil {
+		return 0, nil, err
+	}
+	if cmd.ID == nil {
+		return 0, nil, fieldError(codeMissingField, "id", "required")
+	}
+	return *cmd.ID, nil, s.Release(*cmd.ID, c.id)
+}
+
+// connCommands are handled by Client.execute itself; the rest go through
+// simCommands.
+var connCommands = []string{"viewport", "subscribe", "events_since", "watch_agent", "possess", "act", "release"}
+
+func (c *Client) execute(s *sim.Sim, raw []byte) reply {
+	h, herr := parseHeader(raw)
+	if !c.limiter.allow(time.Now()) {
+		return newReply(h, 0, nil, &replyError{Code: codeRateLimited, Message: "too many commands, slow down"})
+	}
+	if herr != nil {
+		return newReply(h, 0, nil, herr)
+	}
+	if err := authorizeCommand(c.role, h, raw); err != nil {
+		return newReply(h, 0, nil, err)
+	}
+	switch h.Type {
+	case "viewport":
+		v, err := parseViewport(raw)
+		if err == nil {
+			c.SetViewport(v)
+		}
+		return newReply(h, 0, nil, err)
+	case "subscribe":
+		f, err := parseEventFilter(raw, s)
+		if err == nil {
+			c.SetFilter(f)
+		}
+		return newReply(h, 0, nil, err)
+	case "events_since":
+		var cmd eventsSinceCmd
+		if err := decodeStrict(raw, &cmd); err != nil {
+			return newReply(h, 0, nil, err)
+		}
+		if cmd.Cursor == nil {
+			return newReply(h, 0, nil, fieldError(codeMissingField, "cursor", "required"))
+		}
+		c.SetCursor(*cmd.Cursor)
+		return newReply(h, 0, nil, nil)
+	case "watch_agent":
+		var cmd agentIDCmd
+		if err := decodeStrict(raw, &cmd); err != nil {
+			return newReply(h, 0, nil, err)
+		}
+		id := 0
+		if cmd.ID != nil {
+			id = *cmd.ID
+		}
+		if _, ok := s.Agent(id); id != 0 && !ok {
+			return newReply(h, 0, nil, sim.ErrAgentNotFound)
+		}
+		c.SetWatch(id)
+		return newReply(h, id, nil, nil)
+	case "possess":
+		id, data, err := c.possess(s, raw)
+		return newReply(h, id, data, err)
+	case "act":
+		id, data, err := c.act(s, raw)
+		return newReply(h, id, data, err)
+	case "release":
+		id, data, err := c.release(s, raw)
+		return newReply(h, id, data, err)
+	}
+	return executeCommand(s, raw)
+}
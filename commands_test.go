@@ -0,0 +1,292 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vl4deee11/aalive/sim"
+)
+
+func TestTrainBrainCommand(t *testing.T) {
+	cfg := sim.DefaultConfig()
+	cfg.Seed, cfg.Width, cfg.Height, cfg.InitialAgents = 1, 20, 20, 5
+	s := sim.NewSimWithConfig(cfg)
+
+	tests := []struct {
+		name string
+		cmd  string
+		code string
+	}{
+		{"no status yet", `{"type":"training_status"}`, codeNotFound},
+		{"no demonstrations", `{"type":"train_brain"}`, codeConflict},
+		{"over the work cap", `{"type":"train_brain","source":"heuristic","samples":50000,"epochs":1000}`, codeInvalidField},
+		{"samples for demonstrations", `{"type":"train_brain","samples":5}`, codeInvalidField},
+		{"unknown source", `{"type":"train_brain","source":"dreams"}`, codeInvalidField},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := executeCommand(s, []byte(tt.cmd))
+			if rep.OK || rep.Error.Code != tt.code {
+				t.Fatalf("reply = %+v, want %s", rep, tt.code)
+			}
+		})
+	}
+
+	rep := executeCommand(s, []byte(`{"type":"train_brain","source":"heuristic","samples":200,"epochs":50}`))
+	job, _ := rep.Data.(sim.TrainJob)
+	if !rep.OK || rep.ID == 0 || job.ID != rep.ID || job.State != sim.JobRunning {
+		t.Fatalf("train_brain reply = %+v", rep)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status := executeCommand(s, []byte(`{"type":"training_status"}`))
+		job, _ = status.Data.(sim.TrainJob)
+		if job.State != sim.JobRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("training did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if job.State != sim.JobDone || job.Report == nil || job.Report.Samples != 200 {
+		t.Fatalf("finished job = %+v", job)
+	}
+	if _, ok := s.TrainedBrain(); !ok {
+		t.Fatal("trained brain not set")
+	}
+}
+
+func TestInterventionCommands(t *testing.T) {
+	type fixture struct {
+		s          *sim.Sim
+		a, b       int
+		brainA     sim.Brain
+		brainB     sim.Brain
+		foodBefore int
+	}
+	tests := []struct {
+		name   string
+		cmd    string
+		code   string
+		events []sim.EventType
+		check  func(t *testing.T, w fixture, rep reply, events []sim.Event)
+	}{
+		{"kill", `{"type":"kill_agent","id":{a}}`, "", []sim.EventType{sim.EventAgentRemoved, sim.EventDeath}, func(t *testing.T, w fixture, _ reply, events []sim.Event) {
+			if _, ok := w.s.Agent(w.a); ok {
+				t.Error("agent still alive")
+			}
+			if events[1].Cause != sim.CauseRemoved || events[0].ActorID != w.a {
+				t.Errorf("events = %+v", events)
+			}
+		}},
+		{"kill unknown agent", `{"type":"kill_agent","id":999}`, codeNotFound, nil, nil},
+		{"kill without id", `{"type":"kill_agent"}`, codeMissingField, nil, nil},
+		{"teleport", `{"type":"teleport_agent","id":{a},"x":7,"y":3}`, "", []sim.EventType{sim.EventAgentTeleported}, func(t *testing.T, w fixture, _ reply, events []sim.Event) {
+			if v, _ := w.s.Agent(w.a); v.X != 7 || v.Y != 3 {
+				t.Errorf("agent at %d,%d", v.X, v.Y)
+			}
+			if e := events[0]; e.X != 7 || e.Y != 3 || !reflect.DeepEqual(e.From, []int{2, 2}) {
+				t.Errorf("event = %+v", e)
+			}
+		}},
+		{"teleport out of bounds", `{"type":"teleport_agent","id":{a},"x":10,"y":3}`, codeOutOfBounds, nil, func(t *testing.T, w fixture, _ reply, _ []sim.Event) {
+			if v, _ := w.s.Agent(w.a); v.X != 2 || v.Y != 2 {
+				t.Errorf("agent moved to %d,%d", v.X, v.Y)
+			}
+		}},
+		{"teleport negative", `{"type":"teleport_agent","id":{a},"x":-1,"y":0}`, codeOutOfBounds, nil, nil},
+		{"teleport unknown agent", `{"type":"teleport_agent","id":999,"x":1,"y":1}`, codeNotFound, nil, nil},
+		{"edit", `{"type":"edit_agent","id":{a},"energy":80,"sex":"M","agg":0.9}`, "", []sim.EventType{sim.EventAgentEdited}, func(t *testing.T, w fixture, _ reply, events []sim.Event) {
+			if v, _ := w.s.Agent(w.a); v.Energy != 80 || v.Sex != sim.Male || v.Aggression != 0.9 {
+				t.Errorf("agent = %+v", v)
+			}
+			if f := events[0].Fields; !reflect.DeepEqual(f, []string{"energy", "sex", "agg"}) {
+				t.Errorf("edited fields = %v", f)
+			}
+		}},
+		{"edit bad sex", `{"type":"edit_agent","id":{a},"sex":"X"}`, codeInvalidSex, nil, nil},
+		{"edit unknown agent", `{"type":"edit_agent","id":999,"energy":5}`, codeNotFound, nil, nil},
+		{"clone", `{"type":"clone_agent","id":{a}}`, "", []sim.EventType{sim.EventAgentCloned}, func(t *testing.T, w fixture, rep reply, events []sim.Event) {
+			v, ok := w.s.Agent(rep.ID)
+			if !ok || rep.ID == w.a || v.X != 2 || v.Y != 2 || !reflect.DeepEqual(v.Parents, []int{w.a}) {
+				t.Errorf("clone %d = %+v", rep.ID, v)
+			}
+			if e := events[0]; e.ActorID != rep.ID || e.TargetID != w.a {
+				t.Errorf("event = %+v", e)
+			}
+		}},
+		{"clone unknown agent", `{"type":"clone_agent","id":999}`, codeNotFound, nil, nil},
+		{"reset brain", `{"type":"reset_brain","id":{a}}`, "", []sim.EventType{sim.EventBrainReset}, func(t *testing.T, w fixture, _ reply, _ []sim.Event) {
+			if b, _ := w.s.AgentBrain(w.a); reflect.DeepEqual(b.Weights, w.brainA.Weights) {
+				t.Error("weights unchanged")
+			}
+		}},
+		{"reset brain unknown agent", `{"type":"reset_brain","id":999}`, codeNotFound, nil, nil},
+		{"copy brain", `{"type":"copy_brain","from":{b},"to":{a}}`, "", []sim.EventType{sim.EventBrainCopied}, func(t *testing.T, w fixture, _ reply, events []sim.Event) {
+			if b, _ := w.s.AgentBrain(w.a); !reflect.DeepEqual(b.Weights, w.brainB.Weights) {
+				t.Error("weights not copied")
+			}
+			if e := events[0]; e.ActorID != w.a || e.TargetID != w.b {
+				t.Errorf("event = %+v", e)
+			}
+		}},
+		{"copy brain to unknown agent", `{"type":"copy_brain","from":{b},"to":999}`, codeNotFound, nil, nil},
+		{"copy brain without to", `{"type":"copy_brain","from":{b}}`, codeMissingField, nil, nil},
+		{"remove food", `{"type":"remove_food","x":5,"y":5}`, "", []sim.EventType{sim.EventFoodRemoved}, func(t *testing.T, w fixture, _ reply, events []sim.Event) {
+			if n := len(w.s.Foods()); n != w.foodBefore-1 {
+				t.Errorf("%d foods left", n)
+			}
+			if e := events[0]; e.X != 5 || e.Y != 5 || e.Count != 1 {
+				t.Errorf("event = %+v", e)
+			}
+		}},
+		{"remove food from an empty cell", `{"type":"remove_food","x":4,"y":4}`, codeNotFound, nil, nil},
+		{"remove food out of bounds", `{"type":"remove_food","x":40,"y":4}`, codeOutOfBounds, nil, nil},
+		{"remove food in a region", `{"type":"remove_food","region":{"x":0,"y":0,"w":6,"h":6}}`, "", []sim.EventType{sim.EventFoodRemoved}, func(t *testing.T, w fixture, rep reply, events []sim.Event) {
+			if got := rep.Data.(map[string]int)["removed"]; got != 2 || events[0].Count != 2 || len(w.s.Foods()) != w.foodBefore-2 {
+				t.Errorf("removed %d, event %+v", got, events[0])
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := sim.DefaultConfig()
+			cfg.Seed, cfg.Width, cfg.Height, cfg.InitialAgents, cfg.RandomFood = 1, 10, 10, 0, false
+			s := sim.NewSimWithConfig(cfg)
+			a, err := s.AddAgentAt(2, 2, 50, sim.Female, 0.5, 1, 10, 0.5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := s.AddAgentAt(8, 8, 50, sim.Male, 0.5, 1, 10, 0.5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, xy := range [][2]int{{5, 5}, {1, 1}, {9, 9}} {
+				if err := s.AddFoodAt(xy[0], xy[1], 10); err != nil {
+					t.Fatal(err)
+				}
+			}
+			w := fixture{s: s, a: a, b: b, foodBefore: len(s.Foods())}
+			w.brainA, _ = s.AgentBrain(a)
+			w.brainB, _ = s.AgentBrain(b)
+			cursor := s.LastEventID()
+
+			cmd := strings.NewReplacer("{a}", strconv.Itoa(a), "{b}", strconv.Itoa(b)).Replace(tt.cmd)
+			rep := executeCommand(s, []byte(cmd))
+			code := ""
+			if rep.Error != nil {
+				code = rep.Error.Code
+			}
+			if code != tt.code {
+				t.Fatalf("reply = %+v, want %q", rep, tt.code)
+			}
+			events := s.EventsSince(cursor, 0)
+			types := make([]sim.EventType, len(events))
+			for i, e := range events {
+				types[i] = e.Type
+			}
+			if len(types) != len(tt.events) || (len(types) > 0 && !reflect.DeepEqual(types, tt.events)) {
+				t.Fatalf("events = %v, want %v", types, tt.events)
+			}
+			if tt.check != nil {
+				tt.check(t, w, rep, events)
+			}
+		})
+	}
+}
+
+// dialClient returns a server-side Client wired to a websocket and the
+// connection its frames arrive on.
+func dialClient(t *testing.T, s *sim.Sim) (*Client, *websocket.Conn) {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(srv.Close)
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { peer.Close() })
+	conn := <-conns
+	t.Cleanup(func() { conn.Close() })
+	_ = peer.SetReadDeadline(time.Now().Add(10 * time.Second))
+	return &Client{id: 1, role: roleViewer, conn: conn, epoch: s.Epoch()}, peer
+}
+
+func TestWatchAgentStream(t *testing.T) {
+	cfg := sim.DefaultConfig()
+	cfg.Seed, cfg.Width, cfg.Height, cfg.InitialAgents, cfg.RandomFood = 1, 20, 20, 0, false
+	s := sim.NewSimWithConfig(cfg)
+	a, err := s.AddAgentAt(5, 5, 50, sim.Female, 0.5, 1, 10, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, peer := dialClient(t, s)
+
+	tests := []struct {
+		name   string
+		cmd    string
+		code   string
+		policy bool
+	}{
+		{"watch an agent", `{"type":"watch_agent","id":` + strconv.Itoa(a) + `}`, "", true},
+		{"unknown agent keeps the watch", `{"type":"watch_agent","id":999}`, codeNotFound, true},
+		{"id 0 clears the watch", `{"type":"watch_agent","id":0}`, "", false},
+		{"watch again", `{"type":"watch_agent","id":` + strconv.Itoa(a) + `}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := c.execute(s, []byte(tt.cmd))
+			code := ""
+			if rep.Error != nil {
+				code = rep.Error.Code
+			}
+			if code != tt.code {
+				t.Fatalf("error code = %q, want %q", code, tt.code)
+			}
+			s.Tick()
+			state, _ := (<-s.StateChan).(map[string]interface{})
+			if err := c.SendState(state, s); err != nil {
+				t.Fatal(err)
+			}
+			var frame struct {
+				Type   string `json:"type"`
+				Policy *struct {
+					AgentID int       `json:"agent_id"`
+					Probs   []float64 `json:"probs"`
+				} `json:"policy"`
+			}
+			if err := peer.ReadJSON(&frame); err != nil {
+				t.Fatal(err)
+			}
+			if frame.Type != "state" || (frame.Policy != nil) != tt.policy {
+				t.Fatalf("frame %q has policy %v, want %v", frame.Type, frame.Policy != nil, tt.policy)
+			}
+			if !tt.policy {
+				return
+			}
+			sum := 0.0
+			for _, p := range frame.Policy.Probs {
+				sum += p
+			}
+			if frame.Policy.AgentID != a || len(frame.Policy.Probs) != sim.NumActions || math.Abs(sum-1) > 1e-9 {
+				t.Fatalf("policy for agent %d has probs %v summing to %v", frame.Policy.AgentID, frame.Policy.Probs, sum)
+			}
+		})
+	}
+}
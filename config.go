@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+type serverConfig struct {
+	Addr        string     `json:"addr"`
+	Static      string     `json:"static"`
+	SnapshotDir string     `json:"snapshot_dir"`
+	BrainDir    string     `json:"brain_dir"`
+	Insecure    bool       `json:"insecure"`
+	World       sim.Config `json:"world"`
+}
+
+func loadServerConfig(args []string) (serverConfig, error) {
+	cfg := serverConfig{Static: "static", BrainDir: "brains", World: sim.DefaultConfig()}
+
+	fs := flag.NewFlagSet("aalive", flag.ContinueOnError)
+	path := fs.String("config", "", "path to a JSON config file")
+	addr := fs.String("addr", "", "listen address such as :8080 (default: try 10 ports from $PORT or 8080)")
+	static := fs.String("static", cfg.Static, "directory with the web client")
+	snapshots := fs.String("snapshot-dir", "", "save worlds here on shutdown and load them on startup")
+	brains := fs.String("brain-dir", cfg.BrainDir, "directory of the saved brain library")
+	w := fs.Int("w", cfg.World.Width, "world width")
+	h := fs.Int("h", cfg.World.Height, "world height")
+	tick := fs.Duration("tick", time.Duration(cfg.World.TickMillis)*time.Millisecond, "time between simulation ticks")
+	seed := fs.Int64("seed", 0, "random seed (0 picks one from the clock)")
+	agents := fs.Int("agents", cfg.World.InitialAgents, "initial number of agents")
+	insecure := fs.Bool("insecure", false, "give requests without a token the admin role when AUTH_TOKENS is unset")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *path != "" {
+		raw, err := os.ReadFile(*path)
+		if err != nil {
+			return cfg, err
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			return cfg, fmt.Errorf("%s: %v", *path, err)
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Addr = *addr
+		case "static":
+			cfg.Static = *static
+		case "snapshot-dir":
+			cfg.SnapshotDir = *snapshots
+		case "brain-dir":
+			cfg.BrainDir = *brains
+		case "w":
+			cfg.World.Width = *w
+		case "h":
+			cfg.World.Height = *h
+		case "tick":
+			cfg.World.TickMillis = int(*tick / time.Millisecond)
+		case "seed":
+			cfg.World.Seed = *seed
+		case "agents":
+			cfg.World.InitialAgents = *agents
+		case "insecure":
+			cfg.Insecure = *insecure
+		}
+	})
+	return cfg, cfg.World.Validate()
+}
@@ -0,0 +1,350 @@
+package eventlog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+const (
+	currentName = "events.jsonl"
+	segmentGlob = "events-*.jsonl*"
+)
+
+type Config struct {
+	Dir      string
+	MaxBytes int64
+	MaxAge   time.Duration
+}
+
+type record struct {
+	Kind string `json:"kind"`
+	*sim.Event
+	Metrics *sim.MetricsSample `json:"metrics,omitempty"`
+	Dropped *Dropped           `json:"dropped,omitempty"`
+}
+
+// Dropped marks event IDs that never reached the log.
+type Dropped struct {
+	FromID uint64 `json:"from_id"`
+	ToID   uint64 `json:"to_id"`
+}
+
+type Writer struct {
+	cfg Config
+
+	mu       sync.Mutex
+	f        *os.File
+	size     int64
+	opened   time.Time
+	seq      int
+	gzipping sync.WaitGroup
+}
+
+func Open(cfg Config) (*Writer, error) {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 64 << 20
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = 24 * time.Hour
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	w := &Writer{cfg: cfg}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(filepath.Join(w.cfg.Dir, currentName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f = f
+	w.size = st.Size()
+	w.opened = time.Now()
+	return nil
+}
+
+func (w *Writer) WriteEvent(e sim.Event) error {
+	return w.write(record{Kind: "event", Event: &e})
+}
+
+func (w *Writer) WriteMetrics(m sim.MetricsSample) error {
+	return w.write(record{Kind: "metrics", Metrics: &m})
+}
+
+func (w *Writer) write(r record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return os.ErrClosed
+	}
+	if w.size > 0 && (w.size+int64(len(line)) > w.cfg.MaxBytes || time.Since(w.opened) >= w.cfg.MaxAge) {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := w.f.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+func (w *Writer) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	path := w.segmentPath()
+	if err := os.Rename(filepath.Join(w.cfg.Dir, currentName), path); err != nil {
+		return err
+	}
+	w.gzipping.Add(1)
+	go func() {
+		defer w.gzipping.Done()
+		if err := gzipFile(path); err != nil {
+			log.Printf("eventlog: gzip %s: %v", path, err)
+		}
+	}()
+	return w.open()
+}
+
+// now is the clock segment names are taken from.
+var now = time.Now
+
+// segmentPath names the next segment. seq restarts with the process, so
+// names already taken by an earlier run, plain or gzipped, are skipped
+// rather than renamed over.
+func (w *Writer) segmentPath() string {
+	stamp := now().UTC().Format("20060102T150405")
+	for {
+		w.seq++
+		path := filepath.Join(w.cfg.Dir, fmt.Sprintf("events-%s-%04d.jsonl", stamp, w.seq))
+		if !exists(path) && !exists(path+".gz") {
+			return path
+		}
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(path + ".gz.tmp")
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".gz.tmp", path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Consume writes events after the given ID until the channel closes. Events
+// the subscription dropped show up as gaps in the IDs; they are refetched
+// with since, and a "dropped" record is written for any that are no longer
+// available. since is called once more after the channel closes to catch up.
+func (w *Writer) Consume(events <-chan sim.Event, after uint64, since func(cursor uint64) []sim.Event) {
+	last := after
+	put := func(e sim.Event) {
+		if err := w.WriteEvent(e); err != nil {
+			log.Printf("eventlog: write: %v", err)
+		}
+		last = e.ID
+	}
+	backfill := func(upto uint64) {
+		for _, e := range since(last) {
+			if upto != 0 && e.ID >= upto {
+				break
+			}
+			if e.ID != last+1 {
+				w.dropped(last+1, e.ID-1)
+			}
+			put(e)
+		}
+		if upto != 0 && upto != last+1 {
+			w.dropped(last+1, upto-1)
+		}
+	}
+	for e := range events {
+		if e.ID <= last {
+			continue
+		}
+		if e.ID != last+1 {
+			backfill(e.ID)
+		}
+		put(e)
+	}
+	backfill(0)
+}
+
+func (w *Writer) dropped(from, to uint64) {
+	log.Printf("eventlog: events %d-%d were dropped", from, to)
+	if err := w.write(record{Kind: "dropped", Dropped: &Dropped{FromID: from, ToID: to}}); err != nil {
+		log.Printf("eventlog: write: %v", err)
+	}
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	var err error
+	if w.f != nil {
+		err = w.f.Close()
+		w.f = nil
+	}
+	w.mu.Unlock()
+	w.gzipping.Wait()
+	return err
+}
+
+type Filter struct {
+	Type     sim.EventType
+	Actor    int
+	FromTick int
+	Offset   int
+	Limit    int
+}
+
+func segments(dir string) ([]string, error) {
+	names, err := filepath.Glob(filepath.Join(dir, segmentGlob))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(names)+1)
+	for _, n := range names {
+		if strings.HasSuffix(n, ".tmp") {
+			continue
+		}
+		if strings.HasSuffix(n, ".jsonl") {
+			if _, err := os.Stat(n + ".gz"); err == nil {
+				continue
+			}
+		}
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	if _, err := os.Stat(filepath.Join(dir, currentName)); err == nil {
+		out = append(out, filepath.Join(dir, currentName))
+	}
+	return out, nil
+}
+
+// Query returns a page of logged events and the offset of the next page.
+// done reports that no events matched past this page.
+func Query(dir string, f Filter) (events []sim.Event, next int, done bool, err error) {
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	files, err := segments(dir)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	out := make([]sim.Event, 0)
+	matched := 0
+	more := false
+	for _, path := range files {
+		stop, err := scanFile(path, func(r record) bool {
+			if r.Kind != "event" || r.Event == nil {
+				return true
+			}
+			e := r.Event
+			if (f.Type != "" && e.Type != f.Type) || e.Tick < f.FromTick {
+				return true
+			}
+			if f.Actor != 0 && e.ActorID != f.Actor && e.TargetID != f.Actor {
+				return true
+			}
+			matched++
+			if matched <= f.Offset {
+				return true
+			}
+			if len(out) == f.Limit {
+				more = true
+				return false
+			}
+			out = append(out, *e)
+			return true
+		})
+		if err != nil {
+			return nil, 0, false, err
+		}
+		if stop {
+			break
+		}
+	}
+	return out, f.Offset + len(out), !more, nil
+}
+
+func scanFile(path string, fn func(r record) bool) (bool, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer fh.Close()
+	var rd io.Reader = fh
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(fh)
+		if err != nil {
+			return false, err
+		}
+		defer zr.Close()
+		rd = zr
+	}
+	sc := bufio.NewScanner(rd)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		var r record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			continue
+		}
+		if !fn(r) {
+			return true, nil
+		}
+	}
+	return false, sc.Err()
+}
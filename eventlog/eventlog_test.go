@@ -0,0 +1,246 @@
+package eventlog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+func testEvent(id int) sim.Event {
+	typ := sim.EventBirth
+	if id%2 == 0 {
+		typ = sim.EventDeath
+	}
+	return sim.Event{ID: uint64(id), Tick: id, Type: typ, ActorID: id % 5, X: id, Y: id}
+}
+
+func writeLog(t *testing.T, n int) string {
+	t.Helper()
+	dir := t.TempDir()
+	w, err := Open(Config{Dir: dir, MaxBytes: 1500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= n; i++ {
+		if err := w.WriteEvent(testEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+		if i%10 == 0 {
+			if err := w.WriteMetrics(sim.MetricsSample{Tick: i}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func ids(events []sim.Event) []int {
+	out := make([]int, len(events))
+	for i, e := range events {
+		out[i] = int(e.ID)
+	}
+	return out
+}
+
+func span(from, to, step int) []int {
+	var out []int
+	for i := from; i <= to; i += step {
+		out = append(out, i)
+	}
+	return out
+}
+
+func TestRotationAndQuery(t *testing.T) {
+	dir := writeLog(t, 100)
+	gz, _ := filepath.Glob(filepath.Join(dir, "events-*.jsonl.gz"))
+	if len(gz) < 2 {
+		t.Fatalf("got %d gzipped segments, want several", len(gz))
+	}
+	if plain, _ := filepath.Glob(filepath.Join(dir, "events-*.jsonl")); len(plain) != 0 {
+		t.Fatalf("uncompressed segments left behind: %v", plain)
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		want     []int
+		wantNext int
+		wantDone bool
+	}{
+		{"first page", Filter{Limit: 30}, span(1, 30, 1), 30, false},
+		{"middle page", Filter{Offset: 30, Limit: 30}, span(31, 60, 1), 60, false},
+		{"exact last page", Filter{Offset: 70, Limit: 30}, span(71, 100, 1), 100, true},
+		{"short last page", Filter{Offset: 90, Limit: 30}, span(91, 100, 1), 100, true},
+		{"past the end", Filter{Offset: 200, Limit: 30}, []int{}, 200, true},
+		{"by type", Filter{Type: sim.EventDeath, Offset: 45}, span(92, 100, 2), 50, true},
+		{"by actor", Filter{Actor: 3, Limit: 5}, span(3, 23, 5), 5, false},
+		{"from tick", Filter{FromTick: 96}, span(96, 100, 1), 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, next, done, err := Query(dir, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(events); !reflect.DeepEqual(got, tt.want) || next != tt.wantNext || done != tt.wantDone {
+				t.Fatalf("got %v next %d done %v, want %v next %d done %v", got, next, done, tt.want, tt.wantNext, tt.wantDone)
+			}
+		})
+	}
+
+	var all []int
+	for offset, done := 0, false; !done; {
+		var page []sim.Event
+		var err error
+		page, offset, done, err = Query(dir, Filter{Offset: offset, Limit: 7})
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, ids(page)...)
+	}
+	if !reflect.DeepEqual(all, span(1, 100, 1)) {
+		t.Fatalf("paging returned %v", all)
+	}
+}
+
+func readRecords(t *testing.T, dir string) []string {
+	t.Helper()
+	var out []string
+	_, err := scanFile(filepath.Join(dir, currentName), func(r record) bool {
+		switch r.Kind {
+		case "event":
+			out = append(out, strconv.FormatUint(r.Event.ID, 10))
+		case "dropped":
+			out = append(out, "dropped "+strconv.FormatUint(r.Dropped.FromID, 10)+"-"+strconv.FormatUint(r.Dropped.ToID, 10))
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestConsumeFillsGaps(t *testing.T) {
+	events := func(ids ...int) []sim.Event {
+		out := make([]sim.Event, len(ids))
+		for i, id := range ids {
+			out[i] = testEvent(id)
+		}
+		return out
+	}
+	tests := []struct {
+		name     string
+		after    uint64
+		received []sim.Event
+		ring     []sim.Event
+		want     []string
+	}{
+		{"no gaps", 0, events(1, 2, 3), events(1, 2, 3), []string{"1", "2", "3"}},
+		{"gap still in ring", 0, events(1, 4), events(1, 2, 3, 4), []string{"1", "2", "3", "4"}},
+		{"gap overwritten", 0, events(1, 2, 6), events(4, 5, 6), []string{"1", "2", "dropped 3-3", "4", "5", "6"}},
+		{"gap gone entirely", 0, events(1, 5), nil, []string{"1", "dropped 2-4", "5"}},
+		{"missed before the first", 10, events(13), events(11, 12, 13), []string{"11", "12", "13"}},
+		{"tail caught up on close", 0, events(1, 2), events(1, 2, 3, 4), []string{"1", "2", "3", "4"}},
+		{"duplicates skipped", 2, events(2, 3), events(2, 3), []string{"3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := Open(Config{Dir: dir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ch := make(chan sim.Event, len(tt.received))
+			for _, e := range tt.received {
+				ch <- e
+			}
+			close(ch)
+			since := func(cursor uint64) []sim.Event {
+				var out []sim.Event
+				for _, e := range tt.ring {
+					if e.ID > cursor {
+						out = append(out, e)
+					}
+				}
+				return out
+			}
+			w.Consume(ch, tt.after, since)
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := readRecords(t, dir); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("log = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func writeEvents(t *testing.T, dir string, from, to int) {
+	t.Helper()
+	w, err := Open(Config{Dir: dir, MaxBytes: 1500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := from; i <= to; i++ {
+		if err := w.WriteEvent(testEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotateAfterRestart(t *testing.T) {
+	defer func(old func() time.Time) { now = old }(now)
+	stamp := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time { return stamp }
+
+	tests := []struct {
+		name  string
+		first func(t *testing.T, dir string) int
+	}{
+		{"gzipped segments from the last run", func(t *testing.T, dir string) int {
+			writeEvents(t, dir, 1, 100)
+			return 100
+		}},
+		{"uncompressed segment left by a crash", func(t *testing.T, dir string) int {
+			var lines []byte
+			for i := 1; i <= 3; i++ {
+				e := testEvent(i)
+				line, _ := json.Marshal(record{Kind: "event", Event: &e})
+				lines = append(append(lines, line...), '\n')
+			}
+			path := filepath.Join(dir, "events-"+stamp.Format("20060102T150405")+"-0001.jsonl")
+			if err := os.WriteFile(path, lines, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			return 3
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			last := tt.first(t, dir)
+			writeEvents(t, dir, last+1, last+100)
+
+			events, _, done, err := Query(dir, Filter{Limit: 1000})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(events); !done || !reflect.DeepEqual(got, span(1, last+100, 1)) {
+				t.Fatalf("after a restart the log holds %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import "github.com/vl4deee11/aalive/sim"
+
+type eventFilter struct {
+	types  map[sim.EventType]bool
+	agents map[int]bool
+	family bool
+	region *sim.Rect
+}
+
+type subscribeCmd struct {
+	commandHeader
+	Events       []string  `json:"events"`
+	Agents       []int     `json:"agents"`
+	FollowFamily bool      `json:"follow_family"`
+	Region       *sim.Rect `json:"region"`
+}
+
+func parseEventFilter(raw []byte, s *sim.Sim) (*eventFilter, error) {
+	var c subscribeCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return nil, err
+	}
+	if c.Region != nil && (c.Region.W <= 0 || c.Region.H <= 0) {
+		return nil, fieldError(codeInvalidField, "region", "w and h must be positive")
+	}
+	f := &eventFilter{family: c.FollowFamily, region: c.Region}
+	if len(c.Events) > 0 {
+		f.types = make(map[sim.EventType]bool, len(c.Events))
+		for _, t := range c.Events {
+			f.types[sim.EventType(t)] = true
+		}
+	}
+	if len(c.Agents) > 0 {
+		f.agents = make(map[int]bool, len(c.Agents))
+		for _, id := range c.Agents {
+			f.agents[id] = true
+			if f.family {
+				for _, d := range s.Descendants(id) {
+					f.agents[d] = true
+				}
+			}
+		}
+	}
+	if f.types == nil && f.agents == nil && f.region == nil {
+		return nil, nil
+	}
+	return f, nil
+}
+
+func (f *eventFilter) match(e sim.Event) bool {
+	f.track(e)
+	if f.types != nil && !f.types[e.Type] {
+		return false
+	}
+	if f.region != nil && !f.region.Contains(e.X, e.Y) {
+		return false
+	}
+	if f.agents == nil {
+		return true
+	}
+	return f.agents[e.ActorID] || (e.TargetID != 0 && f.agents[e.TargetID])
+}
+
+// track adds children of followed agents before the type and region checks,
+// so a family is still followed when its births are filtered out.
+func (f *eventFilter) track(e sim.Event) {
+	if !f.family || f.agents == nil || e.Type != sim.EventBirth {
+		return
+	}
+	for _, p := range e.Parents {
+		if f.agents[p] {
+			f.agents[e.ActorID] = true
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+func TestEventFilterMatch(t *testing.T) {
+	birth := func(child int, parents ...int) sim.Event {
+		return sim.Event{Type: sim.EventBirth, ActorID: child, Parents: parents, X: 50, Y: 50}
+	}
+	death := func(id int) sim.Event { return sim.Event{Type: sim.EventDeath, ActorID: id, X: 1, Y: 1} }
+	eat := func(id int) sim.Event { return sim.Event{Type: sim.EventType("ate"), ActorID: id, X: 1, Y: 1} }
+	tests := []struct {
+		name   string
+		filter eventFilter
+		events []sim.Event
+		want   []bool
+	}{
+		{
+			"deaths of a family",
+			eventFilter{types: map[sim.EventType]bool{sim.EventDeath: true}, agents: map[int]bool{1: true}, family: true},
+			[]sim.Event{birth(10, 1, 2), birth(11, 10, 3), death(11), death(3), death(1)},
+			[]bool{false, false, true, false, true},
+		},
+		{
+			"births outside the region",
+			eventFilter{region: &sim.Rect{W: 10, H: 10}, agents: map[int]bool{1: true}, family: true},
+			[]sim.Event{birth(10, 1, 2), eat(10), eat(2)},
+			[]bool{false, true, false},
+		},
+		{
+			"without follow_family",
+			eventFilter{agents: map[int]bool{1: true}},
+			[]sim.Event{birth(10, 1, 2), eat(10), eat(1)},
+			[]bool{false, false, true},
+		},
+		{
+			"family births",
+			eventFilter{agents: map[int]bool{1: true}, family: true},
+			[]sim.Event{birth(10, 1, 2), birth(12, 4, 5), birth(11, 10, 5), eat(11)},
+			[]bool{true, false, true, true},
+		},
+		{
+			"target",
+			eventFilter{agents: map[int]bool{1: true}},
+			[]sim.Event{{Type: sim.EventType("attack"), ActorID: 5, TargetID: 1}},
+			[]bool{true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.filter
+			for i, e := range tt.events {
+				if got := f.match(e); got != tt.want[i] {
+					t.Fatalf("event %d (%s actor %d) matched = %v, want %v", i, e.Type, e.ActorID, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseEventFilterFollowFamily(t *testing.T) {
+	cfg := sim.DefaultConfig()
+	cfg.Seed, cfg.Width, cfg.Height, cfg.InitialAgents = 1, 20, 20, 2
+	s := sim.NewSimWithConfig(cfg)
+	f, err := parseEventFilter([]byte(`{"type":"subscribe","events":["death"],"agents":[1],"follow_family":true}`), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.match(sim.Event{Type: sim.EventBirth, ActorID: 40, Parents: []int{1, 2}})
+	if !f.match(sim.Event{Type: sim.EventDeath, ActorID: 40}) {
+		t.Fatal("death of a child born after subscribing was filtered out")
+	}
+	if f, err := parseEventFilter([]byte(`{"type":"subscribe"}`), s); f != nil || err != nil {
+		t.Fatalf("empty subscribe = %v, %v; want no filter", f, err)
+	}
+	if _, err := parseEventFilter([]byte(`{"type":"subscribe","region":{"x":0,"y":0,"w":0,"h":3}}`), s); err == nil {
+		t.Fatal("empty region accepted")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+var (
+	errBrainNotFound = errors.New("brain not found")
+	errBrainExists   = errors.New("brain already exists")
+)
+
+type brainOrigin struct {
+	AgentID int `json:"agent_id,omitempty"`
+	Tick    int `json:"tick,omitempty"`
+}
+
+type brainFile struct {
+	Name    string      `json:"name"`
+	SavedAt time.Time   `json:"saved_at"`
+	Origin  brainOrigin `json:"origin"`
+	Brain   sim.Brain   `json:"brain"`
+}
+
+type brainInfo struct {
+	Name    string      `json:"name"`
+	SavedAt time.Time   `json:"saved_at"`
+	Origin  brainOrigin `json:"origin"`
+}
+
+// brainLibrary keeps named brains as JSON files in one directory, shared by
+// all worlds.
+type brainLibrary struct {
+	dir string
+}
+
+var library = &brainLibrary{dir: "brains"}
+
+func (l *brainLibrary) path(name string) (string, error) {
+	if !worldNameRe.MatchString(name) {
+		return "", fieldError(codeInvalidField, "name", "must be 1-32 letters, digits, '-' or '_'")
+	}
+	return filepath.Join(l.dir, name+".json"), nil
+}
+
+func (l *brainLibrary) list() ([]brainInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(l.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]brainInfo, 0, len(paths))
+	for _, p := range paths {
+		f, err := l.load(strings.TrimSuffix(filepath.Base(p), ".json"))
+		if err != nil {
+			continue
+		}
+		out = append(out, brainInfo{Name: f.Name, SavedAt: f.SavedAt, Origin: f.Origin})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (l *brainLibrary) load(name string) (brainFile, error) {
+	f := brainFile{Brain: sim.DefaultBrainSettings()}
+	path, err := l.path(name)
+	if err != nil {
+		return f, err
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, errBrainNotFound
+	}
+	if err != nil {
+		return f, err
+	}
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return f, err
+	}
+	f.Name = name
+	return f, f.Brain.Validate()
+}
+
+func (l *brainLibrary) save(f brainFile, overwrite bool) error {
+	path, err := l.path(f.Name)
+	if err != nil {
+		return err
+	}
+	if err := f.Brain.Validate(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil && !overwrite {
+		return errBrainExists
+	}
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return err
+	}
+	if f.SavedAt.IsZero() {
+		f.SavedAt = time.Now().UTC()
+	}
+	raw, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (l *brainLibrary) remove(name string) error {
+	path, err := l.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
+		return errBrainNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// resolveBrain reads a "brain" command field: either the name of a library
+// entry or an inline brain object.
+func resolveBrain(raw json.RawMessage) (sim.Brain, error) {
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		f, err := library.load(name)
+		return f.Brain, err
+	}
+	b := sim.DefaultBrainSettings()
+	if err := decodeStrict(raw, &b); err != nil {
+		return b, fieldError(codeInvalidField, "brain", "must be a library name or a brain object")
+	}
+	return b, b.Validate()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+func TestResolveBrain(t *testing.T) {
+	dir := t.TempDir()
+	defer func(old *brainLibrary) { library = old }(library)
+	library = &brainLibrary{dir: dir}
+
+	rows := make([][]float64, 9)
+	for i := range rows {
+		rows[i] = make([]float64, 5)
+	}
+	weights, _ := json.Marshal(rows)
+	shape := `"weights":` + string(weights) + `,"critic_w":[0,0,0,0,0]`
+	// A file written before r_eps, adv_clip and r_est_alpha existed.
+	old := `{"name":"old","brain":{` + shape + `,"lr":0.02}}`
+	if err := os.WriteFile(filepath.Join(dir, "old.json"), []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := sim.DefaultBrainSettings()
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr error
+		wantLR  float64
+	}{
+		{"inline defaults", `{` + shape + `}`, nil, d.LearningRate},
+		{"inline partial", `{` + shape + `,"lr":0.5}`, nil, 0.5},
+		{"library old file", `"old"`, nil, 0.02},
+		{"library missing", `"nope"`, errBrainNotFound, 0},
+		{"explicit zero r_eps", `{` + shape + `,"r_eps":0}`, sim.ErrInvalidBrain, 0},
+		{"explicit zero adv_clip", `{` + shape + `,"adv_clip":0}`, sim.ErrInvalidBrain, 0},
+		{"r_est_alpha above one", `{` + shape + `,"r_est_alpha":2}`, sim.ErrInvalidBrain, 0},
+		{"wrong shape", `{"weights":[[1]],"critic_w":[0]}`, sim.ErrInvalidBrain, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := resolveBrain(json.RawMessage(tt.raw))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.LearningRate != tt.wantLR || b.REps != d.REps || b.AdvClip != d.AdvClip || b.REstAlpha != d.REstAlpha {
+				t.Fatalf("resolved %+v", b)
+			}
+		})
+	}
+}
+
+func TestBrainOverwriteRoles(t *testing.T) {
+	defer func(old *brainLibrary) { library = old }(library)
+	library = &brainLibrary{dir: t.TempDir()}
+	auth := &authConfig{anonymous: roleViewer, tokens: []authToken{{token: "op", role: roleOperator}, {token: "root", role: roleAdmin}}}
+	h, wd := newTestAPI(t, auth)
+
+	rows := make([][]float64, 9)
+	for i := range rows {
+		rows[i] = make([]float64, 5)
+	}
+	weights, _ := json.Marshal(rows)
+	upload := `{"brain":{"weights":` + string(weights) + `,"critic_w":[0,0,0,0,0]}}`
+
+	rest := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+		code   string
+	}{
+		{"operator saves a new entry", http.MethodPut, "/api/v1/brains/a", "op", http.StatusOK, ""},
+		{"operator saves over it", http.MethodPut, "/api/v1/brains/a", "op", http.StatusConflict, codeConflict},
+		{"operator overwrites", http.MethodPut, "/api/v1/brains/a?overwrite=true", "op", http.StatusForbidden, codeForbidden},
+		{"admin overwrites", http.MethodPut, "/api/v1/brains/a?overwrite=true", "root", http.StatusOK, ""},
+		{"operator deletes", http.MethodDelete, "/api/v1/brains/a", "op", http.StatusForbidden, codeForbidden},
+	}
+	for _, tt := range rest {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := doJSON(t, h, tt.method, tt.path, tt.token, upload)
+			if status != tt.status || errorCode(body) != tt.code {
+				t.Fatalf("%s %s = %d %v, want %d %q", tt.method, tt.path, status, body, tt.status, tt.code)
+			}
+		})
+	}
+
+	id := wd.sim.Agents()[0].ID
+	export := func(extra string) string {
+		return `{"type":"export_brain","id":` + strconv.Itoa(id) + `,"name":"b"` + extra + `}`
+	}
+	ws := []struct {
+		name string
+		role role
+		cmd  string
+		code string
+	}{
+		{"operator exports a new entry", roleOperator, export(""), ""},
+		{"operator exports over it", roleOperator, export(""), codeConflict},
+		{"operator overwrites", roleOperator, export(`,"overwrite":true`), codeForbidden},
+		{"admin overwrites", roleAdmin, export(`,"overwrite":true`), ""},
+	}
+	for _, tt := range ws {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{id: 1, role: tt.role}
+			rep := c.execute(wd.sim, []byte(tt.cmd))
+			code := ""
+			if rep.Error != nil {
+				code = rep.Error.Code
+			}
+			if code != tt.code {
+				t.Fatalf("reply = %+v, want %q", rep, tt.code)
+			}
+		})
+	}
+}
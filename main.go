@@ -1,23 +1,46 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/websocket"
 
 	"github.com/vl4deee11/aalive/sim"
 )
 
-var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+var upgrader = websocket.Upgrader{}
+
+const maxEventsPerFrame = 500
 
 type Client struct {
-	conn *websocket.Conn
-	mu   sync.Mutex
+	id      int
+	conn    *websocket.Conn
+	mu      sync.Mutex
+	cursor  uint64
+	lang    string
+	filter  *eventFilter
+	view    *viewport
+	role    role
+	limiter *rateLimiter
+	world   string
+	epoch   int
+	watch   int
+}
+
+type renderedEvent struct {
+	sim.Event
+	Message string `json:"message"`
 }
 
 func (c *Client) Send(v interface{}) error {
@@ -26,123 +49,201 @@ func (c *Client) Send(v interface{}) error {
 	return c.conn.WriteJSON(v)
 }
 
-func main() {
-	width, height := 100, 100
-	s := sim.NewSim(width, height)
-	go s.Run()
+func (c *Client) Close(code int, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	msg := websocket.FormatCloseMessage(code, text)
+	_ = c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	c.conn.Close()
+}
 
-	clients := make(map[*Client]struct{})
-	clientsMu := sync.Mutex{}
+func (c *Client) SetCursor(cursor uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cursor = cursor
+}
 
-	go func() {
-		for state := range s.StateChan {
+func (c *Client) SetFilter(f *eventFilter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.filter = f
+}
 
-			clientsMu.Lock()
-			list := make([]*Client, 0, len(clients))
-			for c := range clients {
-				list = append(list, c)
-			}
-			clientsMu.Unlock()
-
-			for _, c := range list {
-				if err := c.Send(state); err != nil {
-					log.Printf("client send error: %v", err)
-					clientsMu.Lock()
-					delete(clients, c)
-					clientsMu.Unlock()
-					c.conn.Close()
-				}
-			}
+func (c *Client) SetViewport(v *viewport) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.view = v
+}
+
+func (c *Client) SetWatch(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watch = id
+}
+
+func (c *Client) configMessage(cfg sim.Config) map[string]interface{} {
+	return map[string]interface{}{"type": "config", "world": c.world, "w": cfg.Width, "h": cfg.Height, "role": c.role.String()}
+}
+
+func (c *Client) SendState(state map[string]interface{}, s *sim.Sim) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if epoch, _ := state["epoch"].(int); epoch != c.epoch {
+		if err := c.conn.WriteJSON(c.configMessage(s.Config())); err != nil {
+			return err
 		}
-	}()
+		c.epoch = epoch
+		c.view = nil
+	}
+	events := s.EventsSince(c.cursor, maxEventsPerFrame)
+	msg := make(map[string]interface{}, len(state)+1)
+	for k, v := range state {
+		msg[k] = v
+	}
+	rendered := make([]renderedEvent, 0, len(events))
+	for _, e := range events {
+		if c.filter != nil && !c.filter.match(e) {
+			continue
+		}
+		rendered = append(rendered, renderedEvent{Event: e, Message: sim.RenderEvent(e, c.lang)})
+	}
+	msg["events"] = rendered
+	if c.watch != 0 {
+		if p, ok := s.Policy(c.watch); ok {
+			msg["policy"] = p
+		}
+	}
+	if c.view != nil {
+		c.view.apply(msg)
+	}
+	if len(events) > 0 {
+		c.cursor = events[len(events)-1].ID
+	}
+	return c.conn.WriteJSON(msg)
+}
+
+func main() {
+	cfg, err := loadServerConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	auth, err := loadAuthConfig(cfg.Insecure)
+	if err != nil {
+		log.Fatalf("auth: %v", err)
+	}
+	if auth.anonymous == roleAdmin {
+		log.Printf("auth: requests without a token have the admin role")
+	}
+	upgrader.CheckOrigin = auth.checkOrigin
+	library.dir = cfg.BrainDir
+
+	metrics := newServerMetrics()
+	worlds := newWorldManager(os.Getenv("EVENT_LOG_DIR"), cfg.World, metrics)
+	if cfg.SnapshotDir != "" {
+		n, err := worlds.loadSnapshots(cfg.SnapshotDir)
+		if err != nil {
+			log.Fatalf("snapshot: %v", err)
+		}
+		log.Printf("loaded %d world(s) from %s", n, cfg.SnapshotDir)
+	}
+	if _, ok := worlds.get(defaultWorld); !ok {
+		if _, err := worlds.create(worldSpec{Name: defaultWorld, Config: cfg.World}); err != nil {
+			log.Fatalf("world: %v", err)
+		}
+	}
+
+	var clientsMu sync.Mutex
+	nextClientID := 0
 
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		rl, err := auth.roleFor(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		wd, ok := worlds.get(r.URL.Query().Get("world"))
+		if !ok {
+			http.Error(w, errWorldNotFound.Error(), http.StatusNotFound)
+			return
+		}
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			log.Println("upgrade:", err)
 			return
 		}
-		client := &Client{conn: conn}
 		clientsMu.Lock()
-		clients[client] = struct{}{}
+		nextClientID++
+		client := &Client{id: nextClientID, conn: conn, lang: sim.DefaultLang, role: rl, limiter: auth.newLimiter(), world: wd.name, epoch: wd.sim.Epoch()}
 		clientsMu.Unlock()
+		if lang := r.URL.Query().Get("lang"); sim.SupportedLang(lang) {
+			client.lang = lang
+		}
+		if cur, err := strconv.ParseUint(r.URL.Query().Get("cursor"), 10, 64); err == nil {
+			client.cursor = cur
+		}
+		if !wd.addClient(client) {
+			client.Close(websocket.CloseGoingAway, "world stopped")
+			return
+		}
+		metrics.clientConnected(client)
 
-		_ = client.Send(map[string]interface{}{"type": "config", "w": width, "h": height})
+		_ = client.Send(client.configMessage(wd.sim.Config()))
 
 		for {
-			var msg map[string]interface{}
-			if err := conn.ReadJSON(&msg); err != nil {
+			_, raw, err := conn.ReadMessage()
+			if err != nil {
 				break
 			}
-			typeStr, _ := msg["type"].(string)
-			switch typeStr {
-			case "add_food":
-				if mx, ok := msg["x"].(float64); ok {
-					if my, ok2 := msg["y"].(float64); ok2 {
-						energy := 12.0
-						if e, ok3 := msg["energy"].(float64); ok3 {
-							energy = e
-						}
-						s.AddFoodAt(int(mx), int(my), energy)
-					}
-				}
-			case "toggle_random_food":
-				if en, ok := msg["enabled"].(bool); ok {
-					s.SetRandomFood(en)
-				}
-			case "add_agent":
-				if mx, ok := msg["x"].(float64); ok {
-					if my, ok2 := msg["y"].(float64); ok2 {
-						energy := 40.0
-						if e, ok3 := msg["energy"].(float64); ok3 {
-							energy = e
-						}
-						sexStr := "M"
-						if ss, ok4 := msg["sex"].(string); ok4 {
-							sexStr = ss
-						}
-						agg := 0.5
-						if a2, ok5 := msg["agg"].(float64); ok5 {
-							agg = a2
-						}
-						spd := 1
-						if sp, ok6 := msg["spd"].(float64); ok6 {
-							spd = int(sp)
-						}
-						str := 5.0
-						if st, ok7 := msg["strength"].(float64); ok7 {
-							str = st
-						}
-						repro := 0.05
-						if rp, ok8 := msg["repro"].(float64); ok8 {
-							repro = rp
-						}
-						sex := "M"
-						if sexStr == "F" {
-							sex = "F"
-						}
-						s.AddAgentAt(int(mx), int(my), energy, sim.Sex(sex), agg, spd, str, repro)
-					}
-				}
-			default:
-			}
-			_ = client.Send(map[string]string{"ok": "received"})
+			_ = client.Send(client.execute(wd.sim, raw))
 		}
 
-		clientsMu.Lock()
-		delete(clients, client)
-		clientsMu.Unlock()
+		wd.disconnect(client, metrics)
 		conn.Close()
 	})
 
-	http.Handle("/", http.FileServer(http.Dir("static")))
+	registerAPI(http.DefaultServeMux, worlds, auth)
+
+	http.HandleFunc("/metrics", metrics.handler(worlds))
+
+	http.Handle("/", http.FileServer(http.Dir(cfg.Static)))
+
+	ln, err := listen(cfg.Addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := &http.Server{}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("http serve error: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("http shutdown: %v", err)
+	}
+	worlds.shutdown(cfg.SnapshotDir)
+}
+
+func listen(addr string) (net.Listener, error) {
+	if addr != "" {
+		ln, err := net.Listen("tcp", addr)
+		if err == nil {
+			log.Printf("Server started at %s", addr)
+		}
+		return ln, err
+	}
 
 	basePort := 8080
 	if p := os.Getenv("PORT"); p != "" {
 		fmt.Sscanf(p, "%d", &basePort)
 	}
 
-	started := false
 	for i := 0; i < 10; i++ {
 		port := basePort + i
 		addr := fmt.Sprintf(":%d", port)
@@ -153,13 +254,7 @@ func main() {
 			continue
 		}
 		log.Printf("Server started at http://localhost:%d", port)
-		started = true
-		if err := http.Serve(ln, nil); err != nil {
-			log.Fatalf("http serve error: %v", err)
-		}
-		break
-	}
-	if !started {
-		log.Fatal("unable to start server on any port")
+		return ln, nil
 	}
+	return nil, errors.New("unable to start server on any port")
 }
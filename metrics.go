@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+type serverMetrics struct {
+	mu               sync.Mutex
+	clients          int
+	sendErrors       map[int]uint64
+	sendErrorsClosed uint64
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{sendErrors: make(map[int]uint64)}
+}
+
+func (m *serverMetrics) clientConnected(c *Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clients++
+	m.sendErrors[c.id] = 0
+}
+
+func (m *serverMetrics) clientDisconnected(c *Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sendErrors[c.id]; !ok {
+		return
+	}
+	m.clients--
+	m.sendErrorsClosed += m.sendErrors[c.id]
+	delete(m.sendErrors, c.id)
+}
+
+func (m *serverMetrics) sendError(c *Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sendErrors[c.id]; ok {
+		m.sendErrors[c.id]++
+	} else {
+		m.sendErrorsClosed++
+	}
+}
+
+type worldTelemetry struct {
+	name string
+	t    sim.Telemetry
+}
+
+func (m *serverMetrics) handler(worlds *worldManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list := worlds.list()
+		tels := make([]worldTelemetry, 0, len(list))
+		for _, wd := range list {
+			tels = append(tels, worldTelemetry{name: wd.name, t: wd.sim.Telemetry()})
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		m.write(w, tels)
+	}
+}
+
+func (m *serverMetrics) write(w io.Writer, tels []worldTelemetry) {
+	m.mu.Lock()
+	clients := m.clients
+	closed := m.sendErrorsClosed
+	ids := make([]int, 0, len(m.sendErrors))
+	perClient := make(map[int]uint64, len(m.sendErrors))
+	for id, v := range m.sendErrors {
+		ids = append(ids, id)
+		perClient[id] = v
+	}
+	m.mu.Unlock()
+	sort.Ints(ids)
+
+	fmt.Fprintln(w, "# HELP aalive_tick_duration_seconds Time spent in Sim.Tick.")
+	fmt.Fprintln(w, "# TYPE aalive_tick_duration_seconds histogram")
+	for _, wt := range tels {
+		t := wt.t
+		for i, b := range sim.TickDurationBuckets {
+			fmt.Fprintf(w, "aalive_tick_duration_seconds_bucket{world=%q,le=\"%s\"} %d\n", wt.name, strconv.FormatFloat(b, 'g', -1, 64), t.TickBuckets[i])
+		}
+		fmt.Fprintf(w, "aalive_tick_duration_seconds_bucket{world=%q,le=\"+Inf\"} %d\n", wt.name, t.TicksTotal)
+		fmt.Fprintf(w, "aalive_tick_duration_seconds_sum{world=%q} %g\n", wt.name, t.TickSum)
+		fmt.Fprintf(w, "aalive_tick_duration_seconds_count{world=%q} %d\n", wt.name, t.TicksTotal)
+	}
+
+	writeWorldMetric(w, "aalive_ticks_total", "counter", "Simulation ticks run.", tels, func(t sim.Telemetry) interface{} { return t.TicksTotal })
+	writeWorldMetric(w, "aalive_states_dropped_total", "counter", "State snapshots dropped because StateChan was full.", tels, func(t sim.Telemetry) interface{} { return t.DroppedStates })
+	writeWorldMetric(w, "aalive_event_subscriber_drops_total", "counter", "Events dropped because a subscriber channel was full.", tels, func(t sim.Telemetry) interface{} { return t.SubscriberDrops })
+	writeMetric(w, "aalive_clients_connected", "gauge", "Connected WebSocket clients.", clients)
+
+	fmt.Fprintln(w, "# HELP aalive_client_send_errors_total Failed sends to WebSocket clients.")
+	fmt.Fprintln(w, "# TYPE aalive_client_send_errors_total counter")
+	for _, id := range ids {
+		fmt.Fprintf(w, "aalive_client_send_errors_total{client=\"%d\"} %d\n", id, perClient[id])
+	}
+	fmt.Fprintf(w, "aalive_client_send_errors_total{client=\"closed\"} %d\n", closed)
+
+	writeWorldMetric(w, "aalive_population", "gauge", "Living agents.", tels, func(t sim.Telemetry) interface{} { return t.Population })
+	writeWorldMetric(w, "aalive_foods", "gauge", "Food items on the map.", tels, func(t sim.Telemetry) interface{} { return t.Foods })
+	writeWorldMetric(w, "aalive_births_total", "counter", "Agents born or spawned.", tels, func(t sim.Telemetry) interface{} { return t.BirthsTotal })
+	writeWorldMetric(w, "aalive_deaths_total", "counter", "Agents removed from the world.", tels, func(t sim.Telemetry) interface{} { return t.DeathsTotal })
+	writeWorldMetric(w, "aalive_policy_entropy", "gauge", "Mean entropy of agents' action distributions on the last tick.", tels, func(t sim.Telemetry) interface{} { return t.Learning.PolicyEntropy })
+	writeWorldMetric(w, "aalive_td_error_abs", "gauge", "Mean absolute TD error of the last tick's actor-critic updates.", tels, func(t sim.Telemetry) interface{} { return t.Learning.AbsTD })
+	writeWorldMetric(w, "aalive_advantage_clipped_ratio", "gauge", "Fraction of the last tick's updates whose advantage hit AdvClip.", tels, func(t sim.Telemetry) interface{} { return t.Learning.AdvClipFrac })
+	writeWorldMetric(w, "aalive_agents_quarantined_total", "counter", "Agents removed because their brain diverged to NaN or Inf.", tels, func(t sim.Telemetry) interface{} { return t.Quarantined })
+
+	writeWorldMetric(w, "aalive_policy_weight_norm_mean", "gauge", "Mean L2 norm of agents' policy weights.", tels, func(t sim.Telemetry) interface{} { return t.Learning.WeightNorm.Mean })
+	fmt.Fprintln(w, "# HELP aalive_policy_weight_norm_quantile Quantiles of agents' policy weight norms.")
+	fmt.Fprintln(w, "# TYPE aalive_policy_weight_norm_quantile gauge")
+	for _, wt := range tels {
+		wn := wt.t.Learning.WeightNorm
+		for _, q := range [][2]string{{"0.1", "p10"}, {"0.5", "p50"}, {"0.9", "p90"}} {
+			if v, ok := wn.Quantiles[q[1]]; ok {
+				fmt.Fprintf(w, "aalive_policy_weight_norm_quantile{world=%q,quantile=%q} %g\n", wt.name, q[0], v)
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP aalive_events_total Simulation events by type.")
+	fmt.Fprintln(w, "# TYPE aalive_events_total counter")
+	for _, wt := range tels {
+		types := make([]string, 0, len(wt.t.EventsByType))
+		for k := range wt.t.EventsByType {
+			types = append(types, k)
+		}
+		sort.Strings(types)
+		for _, k := range types {
+			fmt.Fprintf(w, "aalive_events_total{world=%q,type=%q} %d\n", wt.name, k, wt.t.EventsByType[k])
+		}
+	}
+}
+
+func writeMetric(w io.Writer, name, typ, help string, v interface{}) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, typ, name, v)
+}
+
+func writeWorldMetric(w io.Writer, name, typ, help string, tels []worldTelemetry, get func(sim.Telemetry) interface{}) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	for _, wt := range tels {
+		fmt.Fprintf(w, "%s{world=%q} %v\n", name, wt.name, get(wt.t))
+	}
+}
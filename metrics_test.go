@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+type sample struct {
+	name   string
+	labels string
+	value  float64
+}
+
+var sampleLine = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(\{[^}]*\})? (\S+)$`)
+
+// parseExposition checks the text format strictly enough for Prometheus to
+// accept it: every sample belongs to a family declared by HELP and TYPE,
+// histogram buckets are cumulative and end in +Inf == _count.
+func parseExposition(t *testing.T, raw []byte) (map[string]string, []sample) {
+	t.Helper()
+	types := map[string]string{}
+	var samples []sample
+	sc := bufio.NewScanner(bytes.NewReader(raw))
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+		if strings.HasPrefix(line, "# TYPE ") {
+			f := strings.Fields(line)
+			if len(f) != 4 {
+				t.Fatalf("bad TYPE line %q", line)
+			}
+			if _, dup := types[f[2]]; dup {
+				t.Fatalf("family %s declared twice", f[2])
+			}
+			types[f[2]] = f[3]
+			continue
+		}
+		m := sampleLine.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("bad sample line %q", line)
+		}
+		v, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			t.Fatalf("bad value in %q", line)
+		}
+		family := m[1]
+		if types[family] == "" {
+			for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+				if base := strings.TrimSuffix(family, suffix); base != family && types[base] == "histogram" {
+					family = base
+				}
+			}
+		}
+		if types[family] == "" {
+			t.Fatalf("sample %q has no TYPE", line)
+		}
+		samples = append(samples, sample{name: m[1], labels: m[2], value: v})
+	}
+
+	buckets := map[string][]float64{}
+	counts := map[string]float64{}
+	for _, s := range samples {
+		world := s.labels
+		if i := strings.Index(world, ",le="); i >= 0 {
+			world = world[:i] + "}"
+		}
+		switch {
+		case strings.HasSuffix(s.name, "_bucket"):
+			buckets[world] = append(buckets[world], s.value)
+		case strings.HasSuffix(s.name, "_count") && types[strings.TrimSuffix(s.name, "_count")] == "histogram":
+			counts[world] = s.value
+		}
+	}
+	for world, bs := range buckets {
+		for i := 1; i < len(bs); i++ {
+			if bs[i] < bs[i-1] {
+				t.Fatalf("%s: bucket %d (%v) below previous (%v)", world, i, bs[i], bs[i-1])
+			}
+		}
+		if last := bs[len(bs)-1]; last != counts[world] {
+			t.Fatalf("%s: +Inf bucket %v != count %v", world, last, counts[world])
+		}
+	}
+	return types, samples
+}
+
+func scrape(t *testing.T, m *serverMetrics, s *sim.Sim) (map[string]string, map[string]float64) {
+	t.Helper()
+	var buf bytes.Buffer
+	m.write(&buf, []worldTelemetry{{name: "default", t: s.Telemetry()}})
+	types, samples := parseExposition(t, buf.Bytes())
+	values := make(map[string]float64, len(samples))
+	for _, s := range samples {
+		values[s.name+s.labels] = s.value
+	}
+	return types, values
+}
+
+func tickN(s *sim.Sim, n int) {
+	for i := 0; i < n; i++ {
+		s.Tick()
+		select {
+		case <-s.StateChan:
+		default:
+		}
+	}
+}
+
+func TestMetricsCountersSurviveReset(t *testing.T) {
+	cfg := sim.DefaultConfig()
+	cfg.Seed, cfg.Width, cfg.Height, cfg.InitialAgents = 3, 30, 30, 15
+	s := sim.NewSimWithConfig(cfg)
+	m := newServerMetrics()
+	tickN(s, 20)
+	_, before := scrape(t, m, s)
+
+	if _, err := s.Reset(cfg); err != nil {
+		t.Fatal(err)
+	}
+	tickN(s, 3)
+	types, after := scrape(t, m, s)
+
+	checked := 0
+	for key, was := range before {
+		name := key
+		if i := strings.IndexByte(key, '{'); i >= 0 {
+			name = key[:i]
+		}
+		base := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(name, "_bucket"), "_count"), "_sum")
+		if types[name] != "counter" && types[base] != "histogram" {
+			continue
+		}
+		now, ok := after[key]
+		if !ok {
+			t.Errorf("%s disappeared after reset", key)
+			continue
+		}
+		if now < was {
+			t.Errorf("%s went backwards: %v -> %v", key, was, now)
+		}
+		checked++
+	}
+	if checked == 0 {
+		t.Fatal("no counters checked")
+	}
+
+	wantTicks := fmt.Sprintf("aalive_ticks_total{world=%q}", "default")
+	if after[wantTicks] != 23 {
+		t.Fatalf("%s = %v, want 23", wantTicks, after[wantTicks])
+	}
+}
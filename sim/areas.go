@@ -0,0 +1,216 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	EventFoodPainted   EventType = "food_painted"
+	EventAgentsSpawned EventType = "agents_spawned"
+
+	MaxBrushExtent = 4 * MaxWorldSide
+)
+
+var (
+	ErrInvalidBrush = errors.New("brush must be exactly one of rect or circle")
+	ErrEmptyBrush   = errors.New("brush covers no cells inside the world")
+	ErrInvalidArea  = fmt.Errorf("brush size must be positive and coordinates and sizes at most %d", MaxBrushExtent)
+)
+
+type Circle struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	R int `json:"r"`
+}
+
+type Brush struct {
+	Rect   *Rect   `json:"rect,omitempty"`
+	Circle *Circle `json:"circle,omitempty"`
+}
+
+func (b Brush) Validate() error {
+	switch {
+	case b.Rect != nil && b.Circle == nil:
+		r := b.Rect
+		if !inExtent(r.X, r.Y) || r.W <= 0 || r.H <= 0 || r.W > MaxBrushExtent || r.H > MaxBrushExtent {
+			return ErrInvalidArea
+		}
+	case b.Circle != nil && b.Rect == nil:
+		c := b.Circle
+		if !inExtent(c.X, c.Y) || c.R < 0 || c.R > MaxBrushExtent {
+			return ErrInvalidArea
+		}
+	default:
+		return ErrInvalidBrush
+	}
+	return nil
+}
+
+func inExtent(x, y int) bool {
+	return x >= -MaxBrushExtent && x <= MaxBrushExtent && y >= -MaxBrushExtent && y <= MaxBrushExtent
+}
+
+func (b Brush) Bounds() Rect {
+	if b.Circle != nil {
+		c := b.Circle
+		return Rect{X: c.X - c.R, Y: c.Y - c.R, W: 2*c.R + 1, H: 2*c.R + 1}
+	}
+	return *b.Rect
+}
+
+func (b Brush) Contains(x, y int) bool {
+	if b.Circle != nil {
+		dx, dy := x-b.Circle.X, y-b.Circle.Y
+		return dx*dx+dy*dy <= b.Circle.R*b.Circle.R
+	}
+	return b.Rect.Contains(x, y)
+}
+
+// brushCells lists the world cells under a validated brush. Only the part of
+// its bounds inside the world is scanned, so the lock is held for at most W*H
+// steps however large the brush is.
+func (s *Sim) brushCells(b Brush) [][2]int {
+	r := b.Bounds()
+	x0, x1 := clamp(r.X, 0, s.W), clamp(r.X+r.W, 0, s.W)
+	y0, y1 := clamp(r.Y, 0, s.H), clamp(r.Y+r.H, 0, s.H)
+	var cells [][2]int
+	for x := x0; x < x1; x++ {
+		for y := y0; y < y1; y++ {
+			if b.Contains(x, y) {
+				cells = append(cells, [2]int{x, y})
+			}
+		}
+	}
+	return cells
+}
+
+type Range struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+func (r *Range) sample(rng func() float64, def Range) float64 {
+	if r == nil {
+		r = &def
+	}
+	return r.Min + rng()*(r.Max-r.Min)
+}
+
+type SpawnSpec struct {
+	Count    int    `json:"count"`
+	Sex      string `json:"sex,omitempty"`
+	Energy   *Range `json:"energy,omitempty"`
+	Agg      *Range `json:"agg,omitempty"`
+	Spd      *Range `json:"spd,omitempty"`
+	Strength *Range `json:"strength,omitempty"`
+	Repro    *Range `json:"repro,omitempty"`
+}
+
+func (s *Sim) PaintFood(b Brush, density, energy float64) (int, error) {
+	if err := b.Validate(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cells := s.brushCells(b)
+	if len(cells) == 0 {
+		return 0, ErrEmptyBrush
+	}
+	occupied := make(map[int]bool, len(s.agents))
+	for _, a := range s.agents {
+		occupied[a.X*s.H+a.Y] = true
+	}
+	n := 0
+	for _, c := range cells {
+		key := c[0]*s.H + c[1]
+		if occupied[key] || s.foods[key] != nil || s.rand.Float64() >= density {
+			continue
+		}
+		s.foods[key] = &Food{X: c[0], Y: c[1], Energy: energy}
+		n++
+	}
+	area := b.Bounds()
+	s.emit(Event{Type: EventFoodPainted, X: area.X, Y: area.Y, Energy: energy, Count: n, Area: &area})
+	return n, nil
+}
+
+func (s *Sim) SpawnAgents(b Brush, spec SpawnSpec) ([]int, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	var sex Sex
+	if spec.Sex != "" {
+		parsed, err := ParseSex(spec.Sex)
+		if err != nil {
+			return nil, err
+		}
+		sex = parsed
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cells := s.brushCells(b)
+	if len(cells) == 0 {
+		return nil, ErrEmptyBrush
+	}
+	ids := make([]int, 0, spec.Count)
+	for i := 0; i < spec.Count; i++ {
+		c := cells[s.rand.Intn(len(cells))]
+		as := sex
+		if as == "" {
+			as = []Sex{Male, Female}[s.rand.Intn(2)]
+		}
+		s.nextID++
+		a := &Agent{
+			ID:         s.nextID,
+			X:          c[0],
+			Y:          c[1],
+			Energy:     spec.Energy.sample(s.rand.Float64, Range{100, 150}),
+			Sex:        as,
+			Aggression: clampF(spec.Agg.sample(s.rand.Float64, Range{0, 1}), 0, 1),
+			Speed:      clampInt(int(math.Round(spec.Spd.sample(s.rand.Float64, Range{1, 2}))), 1, 5),
+			Strength:   spec.Strength.sample(s.rand.Float64, Range{5, 15}),
+			Repro:      clampF(spec.Repro.sample(s.rand.Float64, Range{0.3, 0.65}), 0, 1),
+			Experience: map[string]int{},
+		}
+		s.plant(a, 0.1)
+		ids = append(ids, a.ID)
+	}
+	area := b.Bounds()
+	s.emit(Event{Type: EventAgentsSpawned, X: area.X, Y: area.Y, Count: len(ids), Area: &area})
+	return ids, nil
+}
+
+func (s *Sim) ClearArea(b Brush, agents, foods bool) (int, int, error) {
+	if err := b.Validate(); err != nil {
+		return 0, 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	na, nf := 0, 0
+	if agents {
+		for _, a := range s.sortedAgents() {
+			if b.Contains(a.X, a.Y) {
+				s.emit(Event{Type: EventAgentRemoved, ActorID: a.ID, ActorSex: a.Sex, X: a.X, Y: a.Y, Energy: a.Energy})
+				s.removeAgent(a, CauseRemoved, 0)
+				na++
+			}
+		}
+	}
+	if foods {
+		energy := 0.0
+		for key, f := range s.foods {
+			if b.Contains(f.X, f.Y) {
+				energy += f.Energy
+				delete(s.foods, key)
+				nf++
+			}
+		}
+		if nf > 0 {
+			area := b.Bounds()
+			s.emit(Event{Type: EventFoodRemoved, X: area.X, Y: area.Y, Energy: energy, Count: nf, Area: &area})
+		}
+	}
+	return na, nf, nil
+}
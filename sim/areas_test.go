@@ -0,0 +1,92 @@
+package sim
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func testSim(t *testing.T, w, h int) *Sim {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Width, cfg.Height = w, h
+	cfg.Seed = 1
+	cfg.InitialAgents = 0
+	cfg.RandomFood = false
+	return NewSimWithConfig(cfg)
+}
+
+func TestBrushValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		brush Brush
+		want  error
+	}{
+		{"rect", Brush{Rect: &Rect{X: 1, Y: 1, W: 3, H: 3}}, nil},
+		{"circle", Brush{Circle: &Circle{X: 5, Y: 5, R: 0}}, nil},
+		{"largest rect", Brush{Rect: &Rect{X: -MaxBrushExtent, Y: -MaxBrushExtent, W: MaxBrushExtent, H: MaxBrushExtent}}, nil},
+		{"neither", Brush{}, ErrInvalidBrush},
+		{"both", Brush{Rect: &Rect{W: 1, H: 1}, Circle: &Circle{}}, ErrInvalidBrush},
+		{"zero width", Brush{Rect: &Rect{W: 0, H: 1}}, ErrInvalidArea},
+		{"negative height", Brush{Rect: &Rect{W: 1, H: -4}}, ErrInvalidArea},
+		{"tall rect", Brush{Rect: &Rect{X: 0, Y: 0, W: 100, H: 3000000}}, ErrInvalidArea},
+		{"far rect", Brush{Rect: &Rect{X: math.MaxInt64 - 1, Y: 0, W: 10, H: 10}}, ErrInvalidArea},
+		{"negative radius", Brush{Circle: &Circle{X: 1, Y: 1, R: -1}}, ErrInvalidArea},
+		{"huge radius", Brush{Circle: &Circle{X: 1, Y: 1, R: math.MaxInt64 / 2}}, ErrInvalidArea},
+		{"far circle", Brush{Circle: &Circle{X: math.MinInt64, Y: 0, R: 1}}, ErrInvalidArea},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.brush.Validate(); !errors.Is(err, tt.want) {
+				t.Fatalf("Validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBrushCellsClipped(t *testing.T) {
+	s := testSim(t, 20, 10)
+	tests := []struct {
+		name  string
+		brush Brush
+		cells int
+	}{
+		{"inside", Brush{Rect: &Rect{X: 2, Y: 3, W: 4, H: 2}}, 8},
+		{"overhanging", Brush{Rect: &Rect{X: -5, Y: -5, W: 8, H: 7}}, 3 * 2},
+		{"whole world", Brush{Rect: &Rect{X: -MaxBrushExtent, Y: -MaxBrushExtent, W: MaxBrushExtent, H: MaxBrushExtent}}, 0},
+		{"covering", Brush{Rect: &Rect{X: -10, Y: -10, W: MaxBrushExtent, H: MaxBrushExtent}}, 200},
+		{"outside", Brush{Rect: &Rect{X: 30, Y: 0, W: 5, H: 5}}, 0},
+		{"unit circle", Brush{Circle: &Circle{X: 5, Y: 5, R: 1}}, 5},
+		{"circle at corner", Brush{Circle: &Circle{X: 0, Y: 0, R: 1}}, 3},
+		{"huge circle", Brush{Circle: &Circle{X: 10, Y: 5, R: MaxBrushExtent}}, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.brush.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(s.brushCells(tt.brush)); got != tt.cells {
+				t.Fatalf("brushCells() covers %d cells, want %d", got, tt.cells)
+			}
+		})
+	}
+}
+
+func TestPaintFoodHugeBrushIsBounded(t *testing.T) {
+	s := testSim(t, 50, 50)
+	start := time.Now()
+	n, err := s.PaintFood(Brush{Circle: &Circle{X: 25, Y: 25, R: MaxBrushExtent}}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 50*50 {
+		t.Fatalf("painted %d cells, want %d", n, 50*50)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("painting took %s", d)
+	}
+	if _, err := s.PaintFood(Brush{Rect: &Rect{W: 100, H: 3000000}}, 1, 10); !errors.Is(err, ErrInvalidArea) {
+		t.Fatalf("tall rect: err = %v, want ErrInvalidArea", err)
+	}
+}
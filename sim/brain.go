@@ -0,0 +1,176 @@
+package sim
+
+const (
+	numActions  = 9
+	numFeatures = 5
+)
+
+func (s *Sim) initBrain(a *Agent, scale float64) {
+	b := DefaultBrainSettings()
+	b.Weights = make([][]float64, numActions)
+	for i := 0; i < numActions; i++ {
+		b.Weights[i] = make([]float64, numFeatures)
+		for j := 0; j < numFeatures; j++ {
+			b.Weights[i][j] = s.rand.NormFloat64() * scale
+		}
+	}
+	b.CriticW = make([]float64, numFeatures)
+	b.apply(a)
+}
+
+// DefaultBrainSettings returns the learner settings new agents start with and
+// no weights. Decode partial brains into it so omitted settings keep their
+// defaults instead of zero.
+func DefaultBrainSettings() Brain {
+	return Brain{
+		LearningRate: 0.03,
+		Gamma:        0.98,
+		CriticLR:     0.06,
+		EntropyBeta:  0.01,
+		AdvClip:      6.0,
+		REstAlpha:    0.01,
+		REps:         1e-8,
+	}
+}
+
+// inheritLearner gives a newborn a fresh critic and its parents' averaged
+// learner settings; without them the first reward normalisation divides by zero.
+func inheritLearner(child, a, b *Agent, nf int) {
+	child.CriticW = make([]float64, nf)
+	child.Gamma = (a.Gamma + b.Gamma) / 2
+	child.CriticLR = (a.CriticLR + b.CriticLR) / 2
+	child.EntropyBeta = (a.EntropyBeta + b.EntropyBeta) / 2
+	child.AdvClip = (a.AdvClip + b.AdvClip) / 2
+	child.REstAlpha = (a.REstAlpha + b.REstAlpha) / 2
+	child.REps = (a.REps + b.REps) / 2
+}
+
+func copyBrain(dst, src *Agent) {
+	brainOf(src).apply(dst)
+}
+
+type Brain struct {
+	Weights      [][]float64 `json:"weights"`
+	CriticW      []float64   `json:"critic_w"`
+	LearningRate float64     `json:"lr"`
+	Gamma        float64     `json:"gamma"`
+	CriticLR     float64     `json:"critic_lr"`
+	EntropyBeta  float64     `json:"entropy_beta"`
+	AdvClip      float64     `json:"adv_clip"`
+	RMean        float64     `json:"r_mean"`
+	RVar         float64     `json:"r_var"`
+	REstAlpha    float64     `json:"r_est_alpha"`
+	REps         float64     `json:"r_eps"`
+}
+
+func brainOf(a *Agent) Brain {
+	w := make([][]float64, len(a.Weights))
+	for i, row := range a.Weights {
+		w[i] = append([]float64(nil), row...)
+	}
+	return Brain{
+		Weights:      w,
+		CriticW:      append([]float64(nil), a.CriticW...),
+		LearningRate: a.LearningRate,
+		Gamma:        a.Gamma,
+		CriticLR:     a.CriticLR,
+		EntropyBeta:  a.EntropyBeta,
+		AdvClip:      a.AdvClip,
+		RMean:        a.RMean,
+		RVar:         a.RVar,
+		REstAlpha:    a.REstAlpha,
+		REps:         a.REps,
+	}
+}
+
+func (b Brain) Validate() error {
+	if len(b.Weights) != numActions || len(b.CriticW) != numFeatures {
+		return ErrInvalidBrain
+	}
+	if !finite(b.CriticW...) || !finite(b.LearningRate, b.Gamma, b.CriticLR, b.EntropyBeta, b.AdvClip, b.RMean, b.RVar, b.REstAlpha, b.REps) {
+		return ErrInvalidBrain
+	}
+	if b.LearningRate < 0 || b.CriticLR < 0 || b.EntropyBeta < 0 || b.RVar < 0 || b.Gamma < 0 || b.Gamma > 1 {
+		return ErrInvalidBrain
+	}
+	// The reward normaliser divides by sqrt(RVar)+REps and the advantage is
+	// clipped to AdvClip, so zeros here mean NaN updates or no learning.
+	if b.REps <= 0 || b.AdvClip <= 0 || b.REstAlpha <= 0 || b.REstAlpha > 1 {
+		return ErrInvalidBrain
+	}
+	for _, row := range b.Weights {
+		if len(row) != numFeatures || !finite(row...) {
+			return ErrInvalidBrain
+		}
+	}
+	return nil
+}
+
+// repaired fills reward-normaliser and clip settings that older versions
+// left at zero for newborns, so their saved worlds still load.
+func (b Brain) repaired() Brain {
+	d := DefaultBrainSettings()
+	if b.REps <= 0 {
+		b.REps = d.REps
+	}
+	if b.AdvClip <= 0 {
+		b.AdvClip = d.AdvClip
+	}
+	if b.REstAlpha <= 0 {
+		b.REstAlpha = d.REstAlpha
+	}
+	if b.Gamma == 0 && b.CriticLR == 0 {
+		b.Gamma, b.CriticLR = d.Gamma, d.CriticLR
+	}
+	if len(b.CriticW) == 0 {
+		b.CriticW = make([]float64, numFeatures)
+	}
+	return b
+}
+
+func (b Brain) apply(a *Agent) {
+	a.Weights = make([][]float64, len(b.Weights))
+	for i, row := range b.Weights {
+		a.Weights[i] = append([]float64(nil), row...)
+	}
+	a.CriticW = append([]float64(nil), b.CriticW...)
+	a.LearningRate = b.LearningRate
+	a.Gamma = b.Gamma
+	a.CriticLR = b.CriticLR
+	a.EntropyBeta = b.EntropyBeta
+	a.AdvClip = b.AdvClip
+	a.RMean = b.RMean
+	a.RVar = b.RVar
+	a.REstAlpha = b.REstAlpha
+	a.REps = b.REps
+	a.LastState = make([]float64, numFeatures)
+	a.LastProbs = make([]float64, numActions)
+	a.TDErrors = nil
+}
+
+const EventBrainLoaded EventType = "brain_loaded"
+
+func (s *Sim) AgentBrain(id int) (Brain, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.agents[id]
+	if !ok {
+		return Brain{}, ErrAgentNotFound
+	}
+	return brainOf(a), nil
+}
+
+func (s *Sim) LoadBrain(id int, b Brain) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.agents[id]
+	if !ok {
+		return ErrAgentNotFound
+	}
+	b.apply(a)
+	s.emit(Event{Type: EventBrainLoaded, ActorID: a.ID, ActorSex: a.Sex, X: a.X, Y: a.Y})
+	return nil
+}
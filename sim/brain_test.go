@@ -0,0 +1,191 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func validBrain() Brain {
+	b := DefaultBrainSettings()
+	b.Weights = make([][]float64, numActions)
+	for i := range b.Weights {
+		b.Weights[i] = make([]float64, numFeatures)
+	}
+	b.CriticW = make([]float64, numFeatures)
+	return b
+}
+
+func TestBrainValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*Brain)
+		ok     bool
+	}{
+		{"defaults", func(b *Brain) {}, true},
+		{"frozen learner", func(b *Brain) { b.LearningRate, b.CriticLR, b.EntropyBeta = 0, 0, 0 }, true},
+		{"alpha one", func(b *Brain) { b.REstAlpha = 1 }, true},
+		{"missing row", func(b *Brain) { b.Weights = b.Weights[1:] }, false},
+		{"short row", func(b *Brain) { b.Weights[3] = b.Weights[3][1:] }, false},
+		{"short critic", func(b *Brain) { b.CriticW = nil }, false},
+		{"zero r_eps", func(b *Brain) { b.REps = 0 }, false},
+		{"zero adv_clip", func(b *Brain) { b.AdvClip = 0 }, false},
+		{"negative adv_clip", func(b *Brain) { b.AdvClip = -1 }, false},
+		{"zero r_est_alpha", func(b *Brain) { b.REstAlpha = 0 }, false},
+		{"r_est_alpha above one", func(b *Brain) { b.REstAlpha = 1.5 }, false},
+		{"gamma above one", func(b *Brain) { b.Gamma = 1.1 }, false},
+		{"negative lr", func(b *Brain) { b.LearningRate = -0.1 }, false},
+		{"negative r_var", func(b *Brain) { b.RVar = -1 }, false},
+		{"nan weight", func(b *Brain) { b.Weights[0][2] = math.NaN() }, false},
+		{"inf critic", func(b *Brain) { b.CriticW[1] = math.Inf(-1) }, false},
+		{"nan r_mean", func(b *Brain) { b.RMean = math.NaN() }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := validBrain()
+			tt.mutate(&b)
+			err := b.Validate()
+			if tt.ok && err != nil {
+				t.Fatalf("Validate() = %v, want nil", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidBrain) {
+				t.Fatalf("Validate() = %v, want ErrInvalidBrain", err)
+			}
+		})
+	}
+}
+
+func TestPartialBrainKeepsDefaults(t *testing.T) {
+	b := DefaultBrainSettings()
+	raw := `{"weights":` + mustJSON(t, validBrain().Weights) + `,"critic_w":[0,0,0,0,0],"lr":0.1}`
+	if err := json.Unmarshal([]byte(raw), &b); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	d := DefaultBrainSettings()
+	if b.LearningRate != 0.1 || b.REps != d.REps || b.AdvClip != d.AdvClip || b.REstAlpha != d.REstAlpha || b.Gamma != d.Gamma {
+		t.Fatalf("partial brain decoded as %+v", b)
+	}
+}
+
+// TestLoadBrainLearnsFinitely plants an imported brain and checks that its
+// first updates, including zero-reward ones, stay finite.
+func TestLoadBrainLearnsFinitely(t *testing.T) {
+	s := testSim(t, 20, 20)
+	id, err := s.AddAgentAt(5, 5, 100, Male, 0.5, 1, 10, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LoadBrain(id, validBrain()); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	a := s.agents[id]
+	features, probs := s.computeFeaturesAndProbs(a)
+	for _, r := range []float64{0, 0, 1, 0} {
+		s.updateActorCritic(a, features, probs, 4, r)
+	}
+	bad := diverged(a)
+	s.mu.Unlock()
+	if bad {
+		t.Fatalf("brain diverged: %+v", brainOf(a))
+	}
+}
+
+func TestLoadSimRepairsZeroedSettings(t *testing.T) {
+	s := testSim(t, 20, 20)
+	if _, err := s.AddAgentAt(1, 1, 100, Female, 0.5, 1, 10, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	snap := s.Snapshot()
+	b := &snap.Agents[0].Brain
+	b.REps, b.AdvClip, b.REstAlpha, b.Gamma, b.CriticLR, b.CriticW = 0, 0, 0, 0, 0, nil
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(snap); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSim(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := loaded.AgentBrain(snap.Agents[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Validate(); err != nil {
+		t.Fatalf("loaded brain %+v: %v", got, err)
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestNewbornInheritsLearner(t *testing.T) {
+	s := testSim(t, 20, 20)
+	mother, err := s.AddAgentAt(5, 5, 100, Female, 0.5, 1, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	father, err := s.AddAgentAt(6, 5, 100, Male, 0.5, 1, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bm, bf := validBrain(), validBrain()
+	bm.Gamma, bm.AdvClip, bm.REps = 0.9, 4, 1e-6
+	bf.Gamma, bf.AdvClip, bf.REps = 0.7, 2, 3e-6
+	if err := s.LoadBrain(mother, bm); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LoadBrain(father, bf); err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.order = s.sortedAgents()
+	if !s.tryReproduce(s.agents[mother]) {
+		t.Fatal("parents did not reproduce")
+	}
+	var child *Agent
+	for id, a := range s.agents {
+		if id != mother && id != father {
+			child = a
+		}
+	}
+	b := brainOf(child)
+	if err := b.Validate(); err != nil {
+		t.Fatalf("newborn brain %+v: %v", b, err)
+	}
+	tests := []struct {
+		name      string
+		got, want float64
+	}{
+		{"gamma", b.Gamma, 0.8},
+		{"adv_clip", b.AdvClip, 3},
+		{"r_eps", b.REps, 2e-6},
+		{"critic_lr", b.CriticLR, bm.CriticLR},
+		{"r_est_alpha", b.REstAlpha, bm.REstAlpha},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 1e-12 {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	features, probs := s.computeFeaturesAndProbs(child)
+	for i := 0; i < 3; i++ {
+		s.updateActorCritic(child, features, probs, 4, 0)
+	}
+	if diverged(child) {
+		t.Fatalf("newborn diverged after unrewarded steps: %+v", brainOf(child))
+	}
+}
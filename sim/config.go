@@ -0,0 +1,204 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	MaxWorldSide  = 2000
+	MinTickMillis = 10
+	MaxInputWait  = 5000
+)
+
+var ErrInvalidConfig = errors.New("invalid config")
+
+const EventWorldReset EventType = "world_reset"
+
+type Config struct {
+	Width           int     `json:"w"`
+	Height          int     `json:"h"`
+	RandomFood      bool    `json:"random_food"`
+	RandomFoodProb  float64 `json:"random_food_prob"`
+	MaxAge          int     `json:"max_age"`
+	TraitStatsEvery int     `json:"trait_stats_every"`
+	Paused          bool    `json:"paused"`
+	TickMillis      int     `json:"tick_ms"`
+	Seed            int64   `json:"seed"`
+	InitialAgents   int     `json:"initial_agents"`
+	InputWaitMillis int     `json:"input_wait_ms"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Width:           100,
+		Height:          100,
+		RandomFood:      true,
+		RandomFoodProb:  0.04,
+		TraitStatsEvery: 10,
+		TickMillis:      200,
+		InitialAgents:   2,
+		InputWaitMillis: 100,
+	}
+}
+
+func (c Config) Validate() error {
+	switch {
+	case c.Width < 1 || c.Width > MaxWorldSide:
+		return fmt.Errorf("%w: w must be between 1 and %d", ErrInvalidConfig, MaxWorldSide)
+	case c.Height < 1 || c.Height > MaxWorldSide:
+		return fmt.Errorf("%w: h must be between 1 and %d", ErrInvalidConfig, MaxWorldSide)
+	case !(c.RandomFoodProb >= 0 && c.RandomFoodProb <= 1):
+		return fmt.Errorf("%w: random_food_prob must be between 0 and 1", ErrInvalidConfig)
+	case c.MaxAge < 0:
+		return fmt.Errorf("%w: max_age must not be negative", ErrInvalidConfig)
+	case c.TraitStatsEvery < 0:
+		return fmt.Errorf("%w: trait_stats_every must not be negative", ErrInvalidConfig)
+	case c.TickMillis < MinTickMillis:
+		return fmt.Errorf("%w: tick_ms must be at least %d", ErrInvalidConfig, MinTickMillis)
+	case c.InitialAgents < 0 || c.InitialAgents > c.Width*c.Height:
+		return fmt.Errorf("%w: initial_agents must be between 0 and w*h", ErrInvalidConfig)
+	case c.InputWaitMillis < 0 || c.InputWaitMillis > MaxInputWait:
+		return fmt.Errorf("%w: input_wait_ms must be between 0 and %d", ErrInvalidConfig, MaxInputWait)
+	}
+	return nil
+}
+
+type ConfigUpdate struct {
+	RandomFood      *bool    `json:"random_food"`
+	RandomFoodProb  *float64 `json:"random_food_prob"`
+	MaxAge          *int     `json:"max_age"`
+	TraitStatsEvery *int     `json:"trait_stats_every"`
+	Paused          *bool    `json:"paused"`
+	TickMillis      *int     `json:"tick_ms"`
+	InputWaitMillis *int     `json:"input_wait_ms"`
+}
+
+func (s *Sim) Config() Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config()
+}
+
+func (s *Sim) config() Config {
+	return Config{
+		Width:           s.W,
+		Height:          s.H,
+		RandomFood:      s.RandomFood,
+		RandomFoodProb:  s.RandomFoodProb,
+		MaxAge:          s.MaxAge,
+		TraitStatsEvery: s.TraitStatsEvery,
+		Paused:          s.paused,
+		TickMillis:      int(s.tickEvery / time.Millisecond),
+		Seed:            s.seed,
+		InitialAgents:   s.initialAgents,
+		InputWaitMillis: int(s.inputWait / time.Millisecond),
+	}
+}
+
+// UpdateConfig applies the set fields of u. The merged config must pass
+// Validate, otherwise nothing changes.
+func (s *Sim) UpdateConfig(u ConfigUpdate) (Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.config()
+	if u.RandomFood != nil {
+		c.RandomFood = *u.RandomFood
+	}
+	if u.RandomFoodProb != nil {
+		c.RandomFoodProb = *u.RandomFoodProb
+	}
+	if u.MaxAge != nil {
+		c.MaxAge = *u.MaxAge
+	}
+	if u.TraitStatsEvery != nil {
+		c.TraitStatsEvery = *u.TraitStatsEvery
+	}
+	if u.Paused != nil {
+		c.Paused = *u.Paused
+	}
+	if u.TickMillis != nil {
+		c.TickMillis = *u.TickMillis
+	}
+	if u.InputWaitMillis != nil {
+		c.InputWaitMillis = *u.InputWaitMillis
+	}
+	if err := c.Validate(); err != nil {
+		return s.config(), err
+	}
+	s.RandomFood = c.RandomFood
+	s.RandomFoodProb = c.RandomFoodProb
+	s.MaxAge = c.MaxAge
+	s.TraitStatsEvery = c.TraitStatsEvery
+	s.paused = c.Paused
+	s.tickEvery = time.Duration(c.TickMillis) * time.Millisecond
+	s.inputWait = time.Duration(c.InputWaitMillis) * time.Millisecond
+	return c, nil
+}
+
+func (s *Sim) Reset(cfg Config) (Config, error) {
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset(cfg)
+	s.epoch++
+	s.emit(Event{Type: EventWorldReset, Count: cfg.InitialAgents, Area: &Rect{W: s.W, H: s.H}})
+	return s.config(), nil
+}
+
+func (s *Sim) Epoch() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.epoch
+}
+
+func (s *Sim) SetPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = paused
+}
+
+func (s *Sim) Paused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+func (s *Sim) Agents() []AgentView {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]AgentView, 0, len(s.agents))
+	for _, a := range s.agents {
+		out = append(out, viewOf(a))
+	}
+	return out
+}
+
+func (s *Sim) Agent(id int) (AgentView, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.agents[id]
+	if !ok {
+		return AgentView{}, false
+	}
+	return viewOf(a), true
+}
+
+func (s *Sim) Foods() []Food {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Food, 0, len(s.foods))
+	for _, f := range s.foods {
+		out = append(out, *f)
+	}
+	return out
+}
+
+func (s *Sim) State() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastState
+}
@@ -0,0 +1,49 @@
+package sim
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestUpdateConfigValidates(t *testing.T) {
+	ptrF := func(v float64) *float64 { return &v }
+	ptrI := func(v int) *int { return &v }
+	tests := []struct {
+		name string
+		u    ConfigUpdate
+		ok   bool
+	}{
+		{"empty", ConfigUpdate{}, true},
+		{"prob", ConfigUpdate{RandomFoodProb: ptrF(0.3)}, true},
+		{"prob above one", ConfigUpdate{RandomFoodProb: ptrF(1.5)}, false},
+		{"prob nan", ConfigUpdate{RandomFoodProb: ptrF(math.NaN())}, false},
+		{"negative max_age", ConfigUpdate{MaxAge: ptrI(-1)}, false},
+		{"negative trait cadence", ConfigUpdate{TraitStatsEvery: ptrI(-3)}, false},
+		{"tick too short", ConfigUpdate{TickMillis: ptrI(MinTickMillis - 1)}, false},
+		{"input wait too long", ConfigUpdate{InputWaitMillis: ptrI(MaxInputWait + 1)}, false},
+		{"one bad field rejects all", ConfigUpdate{MaxAge: ptrI(50), TickMillis: ptrI(0)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testSim(t, 10, 10)
+			before := s.Config()
+			got, err := s.UpdateConfig(tt.u)
+			if tt.ok {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != s.Config() {
+					t.Fatalf("returned %+v, sim has %+v", got, s.Config())
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Fatalf("err = %v, want ErrInvalidConfig", err)
+			}
+			if s.Config() != before {
+				t.Fatalf("rejected update changed config to %+v", s.Config())
+			}
+		})
+	}
+}
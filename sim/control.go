@@ -0,0 +1,281 @@
+package sim
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const NumActions = numActions
+
+var FeatureNames = []string{"bias", "food_dx", "food_dy", "energy", "threat"}
+
+var (
+	ErrInvalidAction = errors.New("action must be between 0 and 8")
+	ErrNotControlled = errors.New("agent is not externally controlled")
+	ErrPossessed     = errors.New("agent is controlled by someone else")
+)
+
+const (
+	EventAgentPossessed EventType = "agent_possessed"
+	EventAgentReleased  EventType = "agent_released"
+)
+
+type control struct {
+	owner    int
+	action   int
+	taken    int
+	external bool
+	reward   float64
+	done     bool
+	cause    DeathCause
+}
+
+type StepInfo struct {
+	X        int        `json:"x"`
+	Y        int        `json:"y"`
+	Energy   float64    `json:"energy"`
+	Age      int        `json:"age"`
+	Action   int        `json:"action"`
+	External bool       `json:"external"`
+	Cause    DeathCause `json:"cause,omitempty"`
+}
+
+type Transition struct {
+	Obs    []float64 `json:"obs"`
+	Reward float64   `json:"reward"`
+	Done   bool      `json:"done"`
+	Info   StepInfo  `json:"info"`
+}
+
+// Control hands the agents' actions to the caller: each tick they take the
+// action set with SetAction, or their own policy's when none is pending.
+func (s *Sim) Control(ids ...int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := s.agents[id]; !ok {
+			return ErrAgentNotFound
+		}
+	}
+	for _, id := range ids {
+		if _, ok := s.control[id]; !ok {
+			s.control[id] = &control{action: -1, taken: -1}
+		}
+	}
+	return nil
+}
+
+// Possess gives owner exclusive control of an agent until it is released or
+// dies. Owner 0 is reserved for Control.
+func (s *Sim) Possess(id, owner int) (AgentView, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.agents[id]
+	if !ok {
+		return AgentView{}, ErrAgentNotFound
+	}
+	if c, ok := s.control[id]; ok {
+		if c.owner != owner {
+			return AgentView{}, ErrPossessed
+		}
+		return viewOf(a), nil
+	}
+	s.control[id] = &control{owner: owner, action: -1, taken: -1}
+	s.emit(Event{Type: EventAgentPossessed, ActorID: a.ID, ActorSex: a.Sex, X: a.X, Y: a.Y})
+	return viewOf(a), nil
+}
+
+func (s *Sim) Act(id, owner, act int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.control[id]; ok && c.owner != owner {
+		return ErrPossessed
+	}
+	return s.setAction(id, act)
+}
+
+func (s *Sim) Release(id, owner int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.control[id]
+	if !ok {
+		return ErrNotControlled
+	}
+	if c.owner != owner {
+		return ErrPossessed
+	}
+	s.release(id)
+	return nil
+}
+
+// ReleaseOwner hands every agent owned by owner back to its own policy.
+func (s *Sim) ReleaseOwner(owner int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, c := range s.control {
+		if c.owner == owner {
+			s.release(id)
+		}
+	}
+}
+
+func (s *Sim) release(id int) {
+	delete(s.control, id)
+	if a, ok := s.agents[id]; ok {
+		s.emit(Event{Type: EventAgentReleased, ActorID: a.ID, ActorSex: a.Sex, X: a.X, Y: a.Y})
+	}
+}
+
+func (s *Sim) Controlled() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]int, 0, len(s.control))
+	for id := range s.control {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (s *Sim) SetAction(id, act int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setAction(id, act)
+}
+
+func (s *Sim) setAction(id, act int) error {
+	c, ok := s.control[id]
+	if !ok {
+		return ErrNotControlled
+	}
+	if act < 0 || act >= numActions {
+		return ErrInvalidAction
+	}
+	c.action = act
+	select {
+	case s.inputReady <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *Sim) Observe(id int) ([]float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.agents[id]
+	if !ok {
+		return nil, ErrAgentNotFound
+	}
+	features, _ := s.computeFeaturesAndProbs(a)
+	return features, nil
+}
+
+// Step sets the given actions, advances one tick and reports what happened
+// to every controlled agent. Agents that died are released.
+func (s *Sim) Step(actions map[int]int) (map[int]Transition, error) {
+	s.mu.Lock()
+	for id, act := range actions {
+		if _, ok := s.control[id]; !ok {
+			s.mu.Unlock()
+			return nil, ErrNotControlled
+		}
+		if act < 0 || act >= numActions {
+			s.mu.Unlock()
+			return nil, ErrInvalidAction
+		}
+	}
+	for id, act := range actions {
+		_ = s.setAction(id, act)
+	}
+	s.mu.Unlock()
+
+	s.Tick()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[int]Transition, len(s.control))
+	for id, c := range s.control {
+		t := Transition{Reward: c.reward, Done: c.done, Info: StepInfo{Action: c.taken, External: c.external, Cause: c.cause}}
+		if a, ok := s.agents[id]; ok && !c.done {
+			t.Obs, _ = s.computeFeaturesAndProbs(a)
+			t.Info.X, t.Info.Y, t.Info.Energy, t.Info.Age = a.X, a.Y, a.Energy, a.Age
+		} else {
+			t.Done = true
+			delete(s.control, id)
+		}
+		c.reward, c.taken, c.external = 0, -1, false
+		out[id] = t
+	}
+	return out, nil
+}
+
+// takeAction swaps in the pending external action for a controlled agent and
+// remembers which action was taken.
+func (s *Sim) takeAction(a *Agent, act int) (int, bool) {
+	c, ok := s.control[a.ID]
+	if !ok {
+		return act, false
+	}
+	external := c.action >= 0
+	if external {
+		act = c.action
+		c.action = -1
+	}
+	c.taken, c.external = act, external
+	return act, external
+}
+
+func (s *Sim) rewardControlled(id int, reward float64) {
+	if c, ok := s.control[id]; ok {
+		c.reward += reward
+	}
+}
+
+func (s *Sim) controlledDied(id int, cause DeathCause) {
+	if c, ok := s.control[id]; ok {
+		c.done, c.cause = true, cause
+	}
+}
+
+// awaitInput holds the next tick for up to the configured input wait while
+// any controlled agent has no action queued.
+func (s *Sim) awaitInput(ctx context.Context) {
+	s.mu.Lock()
+	wait := s.inputWait
+	s.mu.Unlock()
+	if wait <= 0 {
+		return
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for s.awaitingInput() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			return
+		case <-s.inputReady:
+		}
+	}
+}
+
+func (s *Sim) awaitingInput() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.control {
+		if c.action < 0 && !c.done {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Sim) pruneControl() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, c := range s.control {
+		if c.done {
+			delete(s.control, id)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package sim
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// controlSim returns a world with one agent under external control at (10,
+// 10) and one left to its own policy in a far corner.
+func controlSim(t *testing.T) (s *Sim, controlled, free int) {
+	t.Helper()
+	s = testSim(t, 20, 20)
+	controlled, err := s.AddAgentAt(10, 10, 50, Female, 0.5, 1, 10, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	free, err = s.AddAgentAt(0, 0, 50, Male, 0.5, 1, 10, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Control(controlled); err != nil {
+		t.Fatal(err)
+	}
+	return s, controlled, free
+}
+
+func TestStepRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions func(controlled, free int) map[int]int
+		want    error
+	}{
+		{"action too large", func(c, _ int) map[int]int { return map[int]int{c: NumActions} }, ErrInvalidAction},
+		{"negative action", func(c, _ int) map[int]int { return map[int]int{c: -1} }, ErrInvalidAction},
+		{"uncontrolled agent", func(_, f int) map[int]int { return map[int]int{f: 0} }, ErrNotControlled},
+		{"unknown agent", func(_, _ int) map[int]int { return map[int]int{999: 0} }, ErrNotControlled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, c, f := controlSim(t)
+			if _, err := s.Step(tt.actions(c, f)); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if tick := s.ticksElapsed; tick != 0 {
+				t.Fatalf("rejected step advanced the world to tick %d", tick)
+			}
+		})
+	}
+	s, _, _ := controlSim(t)
+	if err := s.Control(999); !errors.Is(err, ErrAgentNotFound) {
+		t.Fatalf("Control(unknown) = %v, want ErrAgentNotFound", err)
+	}
+}
+
+func TestStep(t *testing.T) {
+	tests := []struct {
+		name     string
+		food     bool
+		energy   float64
+		act      *int
+		want     StepInfo
+		reward   float64
+		done     bool
+		released bool
+	}{
+		{"external move", false, 50, intPtr(8), StepInfo{X: 11, Y: 11, Age: 1, Action: 8, External: true}, 0, false, false},
+		{"stay", false, 50, intPtr(4), StepInfo{X: 10, Y: 10, Age: 1, Action: 4, External: true}, 0, false, false},
+		// 10 energy from the food plus 1.5 × (2 cells closer + 2 for eating);
+		// upkeep is paid before the action and is not part of the reward.
+		{"eats food", true, 50, intPtr(8), StepInfo{X: 11, Y: 11, Age: 1, Action: 8, External: true}, 16, false, false},
+		{"starves mid-step", false, 0.05, intPtr(8), StepInfo{Action: -1, Cause: CauseStarved}, 0, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, c, _ := controlSim(t)
+			s.agents[c].Energy = tt.energy
+			if tt.food {
+				if err := s.AddFoodAt(11, 11, 10); err != nil {
+					t.Fatal(err)
+				}
+			}
+			actions := map[int]int{}
+			if tt.act != nil {
+				actions[c] = *tt.act
+			}
+			out, err := s.Step(actions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr, ok := out[c]
+			if !ok || len(out) != 1 {
+				t.Fatalf("transitions = %+v, want one for agent %d", out, c)
+			}
+			tr.Info.Energy = 0
+			if tr.Info != tt.want || tr.Done != tt.done || math.Abs(tr.Reward-tt.reward) > 1e-9 {
+				t.Fatalf("transition = %+v, want info %+v reward %v done %v", tr, tt.want, tt.reward, tt.done)
+			}
+			if tt.done != (tr.Obs == nil) {
+				t.Fatalf("obs = %v with done %v", tr.Obs, tt.done)
+			}
+			if got := len(s.Controlled()) == 0; got != tt.released {
+				t.Fatalf("released = %v, want %v", got, tt.released)
+			}
+			if tt.released {
+				if _, err := s.Step(map[int]int{c: 0}); !errors.Is(err, ErrNotControlled) {
+					t.Fatalf("step for a dead agent: err = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestStepFallsBackToPolicy(t *testing.T) {
+	s, c, f := controlSim(t)
+	out, err := s.Step(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr := out[c]; tr.Info.External || tr.Info.Action < 0 || tr.Info.Action >= NumActions {
+		t.Fatalf("step without an action = %+v, want the agent's own action", tr)
+	}
+	if _, ok := out[f]; ok {
+		t.Fatal("uncontrolled agent reported as a transition")
+	}
+
+	controlled := cloneWeights(s.agents[c].Weights)
+	free := cloneWeights(s.agents[f].Weights)
+	if _, err := s.Step(map[int]int{c: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(controlled, s.agents[c].Weights) {
+		t.Error("externally driven agent learned from an action it did not choose")
+	}
+	if reflect.DeepEqual(free, s.agents[f].Weights) {
+		t.Error("uncontrolled agent stopped learning")
+	}
+}
+
+func intPtr(v int) *int { return &v }
+
+func cloneWeights(w [][]float64) [][]float64 {
+	out := make([][]float64, len(w))
+	for i := range w {
+		out[i] = append([]float64(nil), w[i]...)
+	}
+	return out
+}
+
+func TestPossess(t *testing.T) {
+	s := testSim(t, 20, 20)
+	a, err := s.AddAgentAt(5, 5, 50, Female, 0.5, 1, 10, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.AddAgentAt(15, 15, 50, Male, 0.5, 1, 10, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"first owner possesses", func() error { _, err := s.Possess(a, 1); return err }, nil},
+		{"first owner possesses again", func() error { _, err := s.Possess(a, 1); return err }, nil},
+		{"second owner is rejected", func() error { _, err := s.Possess(a, 2); return err }, ErrPossessed},
+		{"unknown agent", func() error { _, err := s.Possess(999, 2); return err }, ErrAgentNotFound},
+		{"second owner cannot act", func() error { return s.Act(a, 2, 4) }, ErrPossessed},
+		{"owner acts", func() error { return s.Act(a, 1, 4) }, nil},
+		{"invalid action", func() error { return s.Act(a, 1, NumActions) }, ErrInvalidAction},
+		{"act on a free agent", func() error { return s.Act(b, 1, 4) }, ErrNotControlled},
+		{"second owner cannot release", func() error { return s.Release(a, 2) }, ErrPossessed},
+		{"release a free agent", func() error { return s.Release(b, 1) }, ErrNotControlled},
+		{"second owner possesses another", func() error { _, err := s.Possess(b, 2); return err }, nil},
+		{"owner releases", func() error { return s.Release(a, 1) }, nil},
+		{"second owner takes the released agent", func() error { _, err := s.Possess(a, 2); return err }, nil},
+	}
+	for _, tt := range tests {
+		if err := tt.call(); !errors.Is(err, tt.want) {
+			t.Fatalf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	var possessed, released int
+	for _, e := range s.EventsSince(0, 0) {
+		switch e.Type {
+		case EventAgentPossessed:
+			possessed++
+		case EventAgentReleased:
+			released++
+		}
+	}
+	if possessed != 3 || released != 1 {
+		t.Fatalf("possessed/released events = %d/%d, want 3/1", possessed, released)
+	}
+
+	s.ReleaseOwner(2)
+	if got := s.Controlled(); len(got) != 0 {
+		t.Fatalf("controlled after ReleaseOwner = %v", got)
+	}
+}
+
+func TestAwaitInput(t *testing.T) {
+	tests := []struct {
+		name     string
+		wait     time.Duration
+		queue    bool
+		lateAct  time.Duration
+		min, max time.Duration
+	}{
+		{"no wait configured", 0, false, 0, 0, 50 * time.Millisecond},
+		{"falls back after the wait", 40 * time.Millisecond, false, 0, 40 * time.Millisecond, 2 * time.Second},
+		{"action already queued", 5 * time.Second, true, 0, 0, time.Second},
+		{"action arrives while waiting", 5 * time.Second, false, 20 * time.Millisecond, 20 * time.Millisecond, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testSim(t, 20, 20)
+			s.inputWait = tt.wait
+			id, err := s.AddAgentAt(5, 5, 50, Female, 0.5, 1, 10, 0.5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Possess(id, 1); err != nil {
+				t.Fatal(err)
+			}
+			if tt.queue {
+				if err := s.Act(id, 1, 4); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.lateAct > 0 {
+				go func() {
+					time.Sleep(tt.lateAct)
+					_ = s.Act(id, 1, 4)
+				}()
+			}
+			start := time.Now()
+			s.awaitInput(context.Background())
+			if took := time.Since(start); took < tt.min || took > tt.max {
+				t.Fatalf("awaitInput took %v, want %v to %v", took, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestDemosOnlyForPossessedSteps(t *testing.T) {
+	s := testSim(t, 20, 20)
+	a, err := s.AddAgentAt(5, 5, 50, Female, 0.5, 1, 10, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddAgentAt(15, 15, 50, Male, 0.5, 1, 10, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Possess(a, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name  string
+		act   int
+		total int
+	}{
+		{"possessed step with an action", 8, 1},
+		{"possessed step that fell back to the policy", -1, 1},
+		{"another action", 0, 2},
+	}
+	for _, st := range steps {
+		if st.act >= 0 {
+			if err := s.Act(a, 1, st.act); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s.Tick()
+		<-s.StateChan
+		demos := s.Demonstrations(0, 0)
+		if len(demos) != st.total {
+			t.Fatalf("%s: %d demonstrations, want %d", st.name, len(demos), st.total)
+		}
+		if st.act < 0 {
+			continue
+		}
+		last := demos[len(demos)-1]
+		if last.AgentID != a || last.Tick != s.ticksElapsed || last.Action != st.act || len(last.Obs) != len(FeatureNames) {
+			t.Fatalf("%s: last demonstration = %+v, want action %d of agent %d", st.name, last, st.act, a)
+		}
+	}
+}
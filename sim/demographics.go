@@ -0,0 +1,160 @@
+package sim
+
+import "sort"
+
+const (
+	CauseOldAge DeathCause = "old_age"
+
+	rateWindow     = 100
+	pyramidBinSize = 100
+)
+
+type lifeKey struct {
+	sex Sex
+	gen int
+}
+
+type LifeTableRow struct {
+	Sex        Sex     `json:"sex"`
+	Generation int     `json:"gen"`
+	Births     int     `json:"births"`
+	Deaths     int     `json:"deaths"`
+	Alive      int     `json:"alive"`
+	TotalAge   int     `json:"-"`
+	MaxAge     int     `json:"max_age"`
+	AvgLife    float64 `json:"avg_life"`
+}
+
+type PyramidBin struct {
+	From   int `json:"from"`
+	To     int `json:"to"`
+	Male   int `json:"m"`
+	Female int `json:"f"`
+}
+
+type demographics struct {
+	byCause   map[DeathCause]int
+	lifeTable map[lifeKey]*LifeTableRow
+
+	tickBirths, tickDeaths int
+	birthsWin, deathsWin   []int
+	birthsSum, deathsSum   int
+}
+
+func newDemographics() demographics {
+	return demographics{
+		byCause:   map[DeathCause]int{CauseStarved: 0, CauseKilled: 0, CauseMerged: 0, CauseOldAge: 0, CauseRemoved: 0, CauseQuarantined: 0},
+		lifeTable: make(map[lifeKey]*LifeTableRow),
+	}
+}
+
+func (d *demographics) row(k lifeKey) *LifeTableRow {
+	r, ok := d.lifeTable[k]
+	if !ok {
+		r = &LifeTableRow{Sex: k.sex, Generation: k.gen}
+		d.lifeTable[k] = r
+	}
+	return r
+}
+
+func (s *Sim) countBirth(a *Agent) {
+	s.totalBirths++
+	s.tel.births++
+	s.demo.tickBirths++
+	a.life = lifeKey{a.Sex, a.Generation}
+	s.demo.row(a.life).Births++
+}
+
+func (s *Sim) removeAgent(a *Agent, cause DeathCause, by int) {
+	s.totalDeaths++
+	s.tel.deaths++
+	s.totalAgeAtDeath += a.Age
+	s.demo.tickDeaths++
+	s.demo.byCause[cause]++
+	r := s.demo.row(a.life)
+	r.Deaths++
+	r.TotalAge += a.Age
+	if a.Age > r.MaxAge {
+		r.MaxAge = a.Age
+	}
+	s.recordDeath(a, cause, by)
+	s.controlledDied(a.ID, cause)
+	delete(s.agents, a.ID)
+	s.emit(Event{Type: EventDeath, ActorID: a.ID, ActorSex: a.Sex, TargetID: by, X: a.X, Y: a.Y, Age: a.Age, Cause: cause})
+}
+
+func (s *Sim) rollRates() {
+	d := &s.demo
+	d.birthsWin = append(d.birthsWin, d.tickBirths)
+	d.deathsWin = append(d.deathsWin, d.tickDeaths)
+	d.birthsSum += d.tickBirths
+	d.deathsSum += d.tickDeaths
+	if len(d.birthsWin) > rateWindow {
+		d.birthsSum -= d.birthsWin[0]
+		d.deathsSum -= d.deathsWin[0]
+		d.birthsWin = d.birthsWin[1:]
+		d.deathsWin = d.deathsWin[1:]
+	}
+	d.tickBirths, d.tickDeaths = 0, 0
+}
+
+func (s *Sim) demographicMetrics(list []*Agent) map[string]interface{} {
+	d := &s.demo
+
+	byCause := make(map[DeathCause]int, len(d.byCause))
+	for k, v := range d.byCause {
+		byCause[k] = v
+	}
+
+	var pyramid []PyramidBin
+	for _, a := range list {
+		b := a.Age / pyramidBinSize
+		for len(pyramid) <= b {
+			from := len(pyramid) * pyramidBinSize
+			pyramid = append(pyramid, PyramidBin{From: from, To: from + pyramidBinSize - 1})
+		}
+		if a.Sex == Female {
+			pyramid[b].Female++
+		} else {
+			pyramid[b].Male++
+		}
+	}
+
+	alive := make(map[lifeKey]int)
+	for _, a := range list {
+		alive[a.life]++
+	}
+	table := make([]LifeTableRow, 0, len(d.lifeTable))
+	for k, r := range d.lifeTable {
+		row := *r
+		row.Alive = alive[k]
+		if row.Deaths > 0 {
+			row.AvgLife = float64(row.TotalAge) / float64(row.Deaths)
+		}
+		table = append(table, row)
+	}
+	sort.Slice(table, func(i, j int) bool {
+		if table[i].Generation != table[j].Generation {
+			return table[i].Generation < table[j].Generation
+		}
+		return table[i].Sex < table[j].Sex
+	})
+
+	window := len(d.birthsWin)
+	birthRate, deathRate := 0.0, 0.0
+	if window > 0 {
+		birthRate = float64(d.birthsSum) / float64(window)
+		deathRate = float64(d.deathsSum) / float64(window)
+	}
+
+	return map[string]interface{}{
+		"deaths_by_cause": byCause,
+		"age_pyramid":     pyramid,
+		"life_table":      table,
+		"rate_window":     window,
+		"births_window":   d.birthsSum,
+		"deaths_window":   d.deathsSum,
+		"birth_rate":      birthRate,
+		"death_rate":      deathRate,
+	}
+}
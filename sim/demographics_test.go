@@ -0,0 +1,71 @@
+package sim
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLifeTableKeysByBirth(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, s *Sim, id int) *Sim
+	}{
+		{"unchanged", func(t *testing.T, s *Sim, id int) *Sim { return s }},
+		{"sex edited", func(t *testing.T, s *Sim, id int) *Sim {
+			sex := string(Male)
+			if _, err := s.EditAgent(id, AgentEdit{Sex: &sex}); err != nil {
+				t.Fatal(err)
+			}
+			return s
+		}},
+		{"generation raised by a merge", func(t *testing.T, s *Sim, id int) *Sim {
+			s.agents[id].Generation = 3
+			return s
+		}},
+		{"edited then saved and loaded", func(t *testing.T, s *Sim, id int) *Sim {
+			sex := string(Male)
+			if _, err := s.EditAgent(id, AgentEdit{Sex: &sex}); err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := s.Save(&buf); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := LoadSim(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return loaded
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testSim(t, 10, 10)
+			id, err := s.AddAgentAt(5, 5, 50, Female, 0.5, 1, 10, 0.5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s = tt.change(t, s, id)
+			born := lifeKey{Female, 0}
+
+			s.mu.Lock()
+			alive := s.demographicMetrics(s.sortedAgents())["life_table"].([]LifeTableRow)
+			s.mu.Unlock()
+			if len(alive) != 1 || alive[0].Sex != Female || alive[0].Generation != 0 || alive[0].Alive != 1 {
+				t.Fatalf("life table while alive = %+v, want one living F/0 row", alive)
+			}
+
+			if err := s.KillAgent(id); err != nil {
+				t.Fatal(err)
+			}
+			for k, r := range s.demo.lifeTable {
+				if k != born {
+					t.Errorf("unexpected row %+v", *r)
+				}
+			}
+			if r := s.demo.lifeTable[born]; r == nil || r.Births != 1 || r.Deaths != 1 {
+				t.Fatalf("birth row = %+v, want 1 birth and 1 death", r)
+			}
+		})
+	}
+}
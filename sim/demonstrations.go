@@ -0,0 +1,72 @@
+package sim
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+const maxDemonstrations = 100000
+
+// Demonstration is one externally chosen action together with the features
+// the agent saw and the reward it got, for imitation learning.
+type Demonstration struct {
+	Tick    int       `json:"tick"`
+	AgentID int       `json:"agent_id"`
+	Obs     []float64 `json:"obs"`
+	Action  int       `json:"action"`
+	Reward  float64   `json:"reward"`
+	Done    bool      `json:"done,omitempty"`
+}
+
+func (s *Sim) recordDemo(d Demonstration) {
+	s.demos = append(s.demos, d)
+	if len(s.demos) > maxDemonstrations+maxDemonstrations/10 {
+		s.demos = append([]Demonstration(nil), s.demos[len(s.demos)-maxDemonstrations:]...)
+	}
+}
+
+// Demonstrations returns recorded steps after tick since, optionally for one
+// agent only, oldest first.
+func (s *Sim) Demonstrations(agentID, since int) []Demonstration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Demonstration, 0)
+	for _, d := range s.demos {
+		if d.Tick <= since || (agentID != 0 && d.AgentID != agentID) {
+			continue
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+func (s *Sim) ClearDemonstrations() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.demos)
+	s.demos = nil
+	return n
+}
+
+func WriteDemonstrationsCSV(w io.Writer, demos []Demonstration) error {
+	cw := csv.NewWriter(w)
+	header := []string{"tick", "agent_id"}
+	header = append(header, FeatureNames...)
+	header = append(header, "action", "reward", "done")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, d := range demos {
+		row := []string{strconv.Itoa(d.Tick), strconv.Itoa(d.AgentID)}
+		for _, v := range d.Obs {
+			row = append(row, strconv.FormatFloat(v, 'f', 6, 64))
+		}
+		row = append(row, strconv.Itoa(d.Action), strconv.FormatFloat(d.Reward, 'f', 6, 64), strconv.FormatBool(d.Done))
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
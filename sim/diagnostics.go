@@ -0,0 +1,99 @@
+package sim
+
+import "math"
+
+const CauseQuarantined DeathCause = "quarantined"
+
+// LearningStats sums up how the population's learners did on the last tick.
+type LearningStats struct {
+	PolicyEntropy float64      `json:"policy_entropy"`
+	AbsTD         float64      `json:"abs_td"`
+	AdvClipFrac   float64      `json:"adv_clip_frac"`
+	Updates       int          `json:"updates"`
+	WeightNorm    TraitSummary `json:"weight_norm"`
+	Quarantined   int          `json:"quarantined"`
+}
+
+type learning struct {
+	updates, clipped int
+	absTD            float64
+	quarantined      int
+	last             LearningStats
+}
+
+func (l *learning) observe(delta, clip float64) {
+	if math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return
+	}
+	l.updates++
+	l.absTD += math.Abs(delta)
+	if math.Abs(delta) > clip {
+		l.clipped++
+	}
+}
+
+func finite(vs ...float64) bool {
+	for _, v := range vs {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func diverged(a *Agent) bool {
+	for _, row := range a.Weights {
+		if !finite(row...) {
+			return true
+		}
+	}
+	return !finite(a.CriticW...) || !finite(a.RMean, a.RVar)
+}
+
+// quarantineDiverged removes agents whose brain has gone NaN or infinite
+// before their weights reach a state frame, snapshot or brain file.
+func (s *Sim) quarantineDiverged() {
+	for _, a := range s.sortedAgents() {
+		if diverged(a) {
+			s.learn.quarantined++
+			s.tel.quarantined++
+			s.removeAgent(a, CauseQuarantined, 0)
+		}
+	}
+}
+
+func (s *Sim) learningStats(list []*Agent) LearningStats {
+	l := &s.learn
+	ls := LearningStats{Updates: l.updates, Quarantined: l.quarantined}
+	if l.updates > 0 {
+		ls.AbsTD = l.absTD / float64(l.updates)
+		ls.AdvClipFrac = float64(l.clipped) / float64(l.updates)
+	}
+	norms := make([]float64, 0, len(list))
+	acted := 0
+	for _, a := range list {
+		h, sum := 0.0, 0.0
+		for _, p := range a.LastProbs {
+			if p > 0 {
+				h -= p * math.Log(p)
+				sum += p
+			}
+		}
+		if sum > 0 {
+			ls.PolicyEntropy += h
+			acted++
+		}
+		sq := 0.0
+		for _, row := range a.Weights {
+			sq += dot(row, row)
+		}
+		norms = append(norms, math.Sqrt(sq))
+	}
+	if acted > 0 {
+		ls.PolicyEntropy /= float64(acted)
+	}
+	ls.WeightNorm = summarize(norms)
+	l.updates, l.clipped, l.absTD, l.quarantined = 0, 0, 0, 0
+	l.last = ls
+	return ls
+}
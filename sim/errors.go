@@ -0,0 +1,24 @@
+package sim
+
+import "errors"
+
+var (
+	ErrOutOfBounds   = errors.New("coordinates outside the world")
+	ErrCellOccupied  = errors.New("cell is occupied")
+	ErrInvalidSex    = errors.New("sex must be M or F")
+	ErrAgentNotFound = errors.New("agent not found")
+	ErrFoodNotFound  = errors.New("no food at cell")
+	ErrInvalidBrain  = errors.New("brain has the wrong shape or invalid settings")
+)
+
+func ParseSex(v string) (Sex, error) {
+	switch Sex(v) {
+	case Male, Female:
+		return Sex(v), nil
+	}
+	return "", ErrInvalidSex
+}
+
+func (s *Sim) inBounds(x, y int) bool {
+	return x >= 0 && x < s.W && y >= 0 && y < s.H
+}
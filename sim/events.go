@@ -0,0 +1,145 @@
+package sim
+
+const eventBufferSize = 5000
+
+type EventType string
+
+const (
+	EventBirth        EventType = "birth"
+	EventDeath        EventType = "death"
+	EventAttack       EventType = "attack"
+	EventKill         EventType = "kill"
+	EventMerge        EventType = "merge"
+	EventFoodEaten    EventType = "food_eaten"
+	EventFoodAdded    EventType = "food_added"
+	EventAgentSpawned EventType = "agent_spawned"
+)
+
+type Event struct {
+	ID       uint64     `json:"id"`
+	Type     EventType  `json:"type"`
+	Tick     int        `json:"tick"`
+	ActorID  int        `json:"actor_id"`
+	ActorSex Sex        `json:"actor_sex,omitempty"`
+	TargetID int        `json:"target_id,omitempty"`
+	X        int        `json:"x"`
+	Y        int        `json:"y"`
+	Energy   float64    `json:"energy,omitempty"`
+	Damage   float64    `json:"damage,omitempty"`
+	Age      int        `json:"age,omitempty"`
+	Cause    DeathCause `json:"cause,omitempty"`
+	Parents  []int      `json:"parents,omitempty"`
+	From     []int      `json:"from,omitempty"`
+	Fields   []string   `json:"fields,omitempty"`
+	Count    int        `json:"count,omitempty"`
+	Area     *Rect      `json:"area,omitempty"`
+	Job      int        `json:"job,omitempty"`
+}
+
+type eventLog struct {
+	buf    []Event
+	start  int
+	n      int
+	nextID uint64
+
+	subs    map[int]chan Event
+	nextSub int
+}
+
+func newEventLog() eventLog {
+	return eventLog{buf: make([]Event, eventBufferSize), nextID: 1, subs: make(map[int]chan Event)}
+}
+
+func (s *Sim) emit(e Event) {
+	l := &s.events
+	e.ID = l.nextID
+	l.nextID++
+	e.Tick = s.ticksElapsed
+	s.tel.eventsByType[string(e.Type)]++
+	if l.n < len(l.buf) {
+		l.buf[(l.start+l.n)%len(l.buf)] = e
+		l.n++
+	} else {
+		l.buf[l.start] = e
+		l.start = (l.start + 1) % len(l.buf)
+	}
+	for _, ch := range l.subs {
+		select {
+		case ch <- e:
+		default:
+			s.tel.subscriberDrops++
+		}
+	}
+}
+
+func (s *Sim) Subscribe(buffer int) (<-chan Event, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := &s.events
+	l.nextSub++
+	id := l.nextSub
+	ch := make(chan Event, buffer)
+	l.subs[id] = ch
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if c, ok := l.subs[id]; ok {
+			delete(l.subs, id)
+			close(c)
+		}
+	}
+}
+
+func (s *Sim) EventsSince(cursor uint64, limit int) []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.eventsSince(cursor, limit)
+}
+
+func (s *Sim) eventsSince(cursor uint64, limit int) []Event {
+	l := &s.events
+	if l.n == 0 {
+		return nil
+	}
+	first := l.nextID - uint64(l.n)
+	skip := 0
+	if cursor >= first {
+		skip = int(cursor - first + 1)
+	}
+	if skip >= l.n {
+		return nil
+	}
+	count := l.n - skip
+	if limit > 0 && count > limit {
+		count = limit
+	}
+	out := make([]Event, count)
+	for i := 0; i < count; i++ {
+		out[i] = l.buf[(l.start+skip+i)%len(l.buf)]
+	}
+	return out
+}
+
+// restoreEvents continues the event sequence of a saved world, so cursors
+// handed out before the save stay valid. Snapshots without a sequence keep
+// the fresh one.
+func (s *Sim) restoreEvents(nextID uint64, events []Event) {
+	if nextID == 0 {
+		return
+	}
+	l := &s.events
+	if len(events) > len(l.buf) {
+		events = events[len(events)-len(l.buf):]
+	}
+	l.start, l.n = 0, copy(l.buf, events)
+	l.nextID = nextID
+	if l.n > 0 && events[l.n-1].ID != nextID-1 {
+		l.n = 0
+	}
+}
+
+func (s *Sim) LastEventID() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.events.nextID - 1
+}
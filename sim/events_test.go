@@ -0,0 +1,45 @@
+package sim
+
+import "testing"
+
+func TestEventsSinceAfterOverflow(t *testing.T) {
+	s := testSim(t, 10, 10)
+	s.mu.Lock()
+	for i := 0; i < eventBufferSize+1234; i++ {
+		s.emit(Event{Type: EventFoodAdded})
+	}
+	s.mu.Unlock()
+	last := s.LastEventID()
+	first := last - eventBufferSize + 1
+
+	tests := []struct {
+		name      string
+		cursor    uint64
+		limit     int
+		wantFirst uint64
+		wantLen   int
+	}{
+		{"from the start", 0, 0, first, eventBufferSize},
+		{"cursor overwritten long ago", 100, 0, first, eventBufferSize},
+		{"cursor just before the ring", first - 1, 0, first, eventBufferSize},
+		{"cursor on the oldest event", first, 0, first + 1, eventBufferSize - 1},
+		{"cursor inside the ring", last - 10, 0, last - 9, 10},
+		{"cursor overwritten, limited", 100, 25, first, 25},
+		{"cursor inside the ring, limited", first + 99, 7, first + 100, 7},
+		{"caught up", last, 0, 0, 0},
+		{"cursor ahead of the ring", last + 50, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.EventsSince(tt.cursor, tt.limit)
+			if len(got) != tt.wantLen {
+				t.Fatalf("got %d events, want %d", len(got), tt.wantLen)
+			}
+			for i, e := range got {
+				if want := tt.wantFirst + uint64(i); e.ID != want {
+					t.Fatalf("event %d has id %d, want %d", i, e.ID, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package sim
+
+const (
+	historySamplesLen = 500
+	historyEventsLen  = 200
+	historyKeepDead   = 1000
+)
+
+type DeathCause string
+
+const (
+	CauseStarved DeathCause = "starved"
+	CauseKilled  DeathCause = "killed"
+	CauseMerged  DeathCause = "merged"
+)
+
+type HistorySample struct {
+	Tick   int     `json:"tick"`
+	X      int     `json:"x"`
+	Y      int     `json:"y"`
+	Energy float64 `json:"energy"`
+	Action int     `json:"action"`
+}
+
+type LifeEvent struct {
+	Tick    int     `json:"tick"`
+	Kind    string  `json:"kind"`
+	OtherID int     `json:"other_id,omitempty"`
+	X       int     `json:"x"`
+	Y       int     `json:"y"`
+	Amount  float64 `json:"amount,omitempty"`
+}
+
+type AgentHistory struct {
+	ID              int             `json:"id"`
+	Sex             Sex             `json:"sex"`
+	Alive           bool            `json:"alive"`
+	BirthTick       int             `json:"birth_tick"`
+	DeathTick       int             `json:"death_tick,omitempty"`
+	DeathAge        int             `json:"death_age,omitempty"`
+	Cause           DeathCause      `json:"cause,omitempty"`
+	CauseBy         int             `json:"cause_by,omitempty"`
+	Parents         []int           `json:"parents"`
+	Offspring       []int           `json:"offspring"`
+	Samples         []HistorySample `json:"samples"`
+	Actions         map[int]int     `json:"actions"`
+	FoodsEaten      int             `json:"foods_eaten"`
+	AttacksGiven    int             `json:"attacks_given"`
+	AttacksReceived int             `json:"attacks_received"`
+	Matings         int             `json:"matings"`
+	Merges          int             `json:"merges"`
+	Events          []LifeEvent     `json:"events"`
+}
+
+func (s *Sim) recordBirth(a *Agent) {
+	parents := make([]int, len(a.Parents))
+	copy(parents, a.Parents)
+	s.histories[a.ID] = &AgentHistory{
+		ID:        a.ID,
+		Sex:       a.Sex,
+		Alive:     true,
+		BirthTick: s.ticksElapsed,
+		Parents:   parents,
+		Offspring: []int{},
+		Samples:   make([]HistorySample, 0),
+		Actions:   map[int]int{},
+		Events:    make([]LifeEvent, 0),
+	}
+	for _, pid := range a.Parents {
+		if ph, ok := s.histories[pid]; ok {
+			ph.Offspring = append(ph.Offspring, a.ID)
+			ph.Matings++
+			ph.addLifeEvent(LifeEvent{Tick: s.ticksElapsed, Kind: "mating", OtherID: a.ID, X: a.X, Y: a.Y})
+		}
+	}
+}
+
+func (s *Sim) recordStep(a *Agent) {
+	h, ok := s.histories[a.ID]
+	if !ok {
+		return
+	}
+	if len(h.Samples) >= historySamplesLen {
+		h.Samples = h.Samples[1:]
+	}
+	h.Samples = append(h.Samples, HistorySample{Tick: s.ticksElapsed, X: a.X, Y: a.Y, Energy: a.Energy, Action: a.LastAction})
+	h.Actions[a.LastAction]++
+}
+
+func (s *Sim) recordLifeEvent(id int, e LifeEvent) {
+	h, ok := s.histories[id]
+	if !ok {
+		return
+	}
+	e.Tick = s.ticksElapsed
+	switch e.Kind {
+	case "ate":
+		h.FoodsEaten++
+	case "attack_given":
+		h.AttacksGiven++
+	case "attack_received":
+		h.AttacksReceived++
+	case "merge":
+		h.Merges++
+	}
+	h.addLifeEvent(e)
+}
+
+func (s *Sim) recordDeath(a *Agent, cause DeathCause, by int) {
+	h, ok := s.histories[a.ID]
+	if !ok {
+		return
+	}
+	h.Alive = false
+	h.DeathTick = s.ticksElapsed
+	h.DeathAge = a.Age
+	h.Cause = cause
+	h.CauseBy = by
+	s.deadHistory = append(s.deadHistory, a.ID)
+	if len(s.deadHistory) > historyKeepDead {
+		delete(s.histories, s.deadHistory[0])
+		s.deadHistory = s.deadHistory[1:]
+	}
+}
+
+func (h *AgentHistory) addLifeEvent(e LifeEvent) {
+	if len(h.Events) >= historyEventsLen {
+		h.Events = h.Events[1:]
+	}
+	h.Events = append(h.Events, e)
+}
+
+func (h *AgentHistory) clone() *AgentHistory {
+	c := *h
+	c.Parents = append([]int{}, h.Parents...)
+	c.Offspring = append([]int{}, h.Offspring...)
+	c.Samples = append([]HistorySample{}, h.Samples...)
+	c.Events = append([]LifeEvent{}, h.Events...)
+	c.Actions = make(map[int]int, len(h.Actions))
+	for k, v := range h.Actions {
+		c.Actions[k] = v
+	}
+	return &c
+}
+
+func (s *Sim) AgentHistory(id int) (*AgentHistory, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.histories[id]
+	if !ok {
+		return nil, false
+	}
+	return h.clone(), true
+}
+
+func (s *Sim) Descendants(id int) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	children := make(map[int][]int)
+	for c, ps := range s.lineage {
+		for _, p := range ps {
+			children[p] = append(children[p], c)
+		}
+	}
+	out := []int{}
+	seen := map[int]bool{id: true}
+	queue := []int{id}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, c := range children[cur] {
+			if !seen[c] {
+				seen[c] = true
+				out = append(out, c)
+				queue = append(queue, c)
+			}
+		}
+	}
+	return out
+}
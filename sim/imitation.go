@@ -0,0 +1,244 @@
+package sim
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+)
+
+const (
+	EventBrainTrained EventType = "brain_trained"
+
+	MaxTrainEpochs  = 1000
+	MaxTrainSamples = 50000
+)
+
+var (
+	ErrNoSamples   = errors.New("no demonstrations to train on")
+	ErrNoTrained   = errors.New("no trained brain yet")
+	ErrBadTraining = errors.New("invalid training options")
+)
+
+type TrainOptions struct {
+	Epochs int     `json:"epochs"`
+	LR     float64 `json:"lr"`
+	L2     float64 `json:"l2"`
+}
+
+func DefaultTrainOptions() TrainOptions {
+	return TrainOptions{Epochs: 500, LR: 5, L2: 1e-4}
+}
+
+func (o TrainOptions) validate() error {
+	if o.Epochs < 1 || o.Epochs > MaxTrainEpochs || o.LR <= 0 || o.L2 < 0 {
+		return ErrBadTraining
+	}
+	return nil
+}
+
+type TrainReport struct {
+	Source   string  `json:"source"`
+	Samples  int     `json:"samples"`
+	Epochs   int     `json:"epochs"`
+	Loss     float64 `json:"loss"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+// TrainBrain fits the policy weights of base to the demonstrated actions by
+// softmax regression (behavioural cloning). Critic and learning settings are
+// kept from base.
+func TrainBrain(base Brain, demos []Demonstration, opts TrainOptions) (Brain, TrainReport, error) {
+	if err := checkTrainWork(opts.Epochs, len(demos)); err != nil {
+		return Brain{}, TrainReport{}, err
+	}
+	return trainBrain(context.Background(), base, demos, opts)
+}
+
+func trainBrain(ctx context.Context, base Brain, demos []Demonstration, opts TrainOptions) (Brain, TrainReport, error) {
+	if err := opts.validate(); err != nil {
+		return Brain{}, TrainReport{}, err
+	}
+	if err := base.Validate(); err != nil {
+		return Brain{}, TrainReport{}, err
+	}
+	data := make([]Demonstration, 0, len(demos))
+	for _, d := range demos {
+		if len(d.Obs) == numFeatures && d.Action >= 0 && d.Action < numActions {
+			data = append(data, d)
+		}
+	}
+	if len(data) == 0 {
+		return Brain{}, TrainReport{}, ErrNoSamples
+	}
+	var scratch Agent
+	base.apply(&scratch)
+	w := scratch.Weights
+	grad := make([][]float64, numActions)
+	for i := range grad {
+		grad[i] = make([]float64, numFeatures)
+	}
+	probs := make([]float64, numActions)
+	n := float64(len(data))
+	for epoch := 0; epoch < opts.Epochs; epoch++ {
+		if err := ctx.Err(); err != nil {
+			return Brain{}, TrainReport{}, err
+		}
+		for i := range grad {
+			for j := range grad[i] {
+				grad[i][j] = 0
+			}
+		}
+		for _, d := range data {
+			softmax(w, d.Obs, probs)
+			for k := range probs {
+				g := probs[k]
+				if k == d.Action {
+					g--
+				}
+				for j, f := range d.Obs {
+					grad[k][j] += g * f
+				}
+			}
+		}
+		for k := range w {
+			for j := range w[k] {
+				w[k][j] -= opts.LR * (grad[k][j]/n + opts.L2*w[k][j])
+			}
+		}
+	}
+
+	rep := TrainReport{Samples: len(data), Epochs: opts.Epochs}
+	hits := 0
+	for _, d := range data {
+		softmax(w, d.Obs, probs)
+		rep.Loss -= math.Log(math.Max(probs[d.Action], 1e-12))
+		best := 0
+		for k := range probs {
+			if probs[k] > probs[best] {
+				best = k
+			}
+		}
+		if best == d.Action {
+			hits++
+		}
+	}
+	rep.Loss /= n
+	rep.Accuracy = float64(hits) / n
+	return brainOf(&scratch), rep, nil
+}
+
+func softmax(w [][]float64, features, out []float64) {
+	maxl := math.Inf(-1)
+	for k := range out {
+		out[k] = dot(w[k], features)
+		if out[k] > maxl {
+			maxl = out[k]
+		}
+	}
+	sum := 0.0
+	for k := range out {
+		out[k] = math.Exp(out[k] - maxl)
+		sum += out[k]
+	}
+	for k := range out {
+		out[k] /= sum
+	}
+}
+
+// heuristicBatch is how many heuristic samples are labelled per hold of the
+// world lock.
+const heuristicBatch = 256
+
+// HeuristicDemonstrations labels n random cells of the current world with
+// the move the food-seeking bias in chooseAction prefers. The probe has the
+// population's mean strength, so threats look as they do to a typical agent.
+func (s *Sim) HeuristicDemonstrations(n int) []Demonstration {
+	s.mu.Lock()
+	rng := rand.New(rand.NewSource(s.rand.Int63()))
+	strength := s.meanStrength()
+	s.mu.Unlock()
+	out, _ := s.heuristicDemonstrations(context.Background(), rng, strength, n)
+	return out
+}
+
+func (s *Sim) heuristicDemonstrations(ctx context.Context, rng *rand.Rand, strength float64, n int) ([]Demonstration, error) {
+	out := make([]Demonstration, 0, n)
+	for len(out) < n {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		for i := 0; i < heuristicBatch && len(out) < n; i++ {
+			a := &Agent{X: rng.Intn(s.W), Y: rng.Intn(s.H), Energy: rng.Float64() * 150, Strength: strength}
+			features, _ := s.computeFeaturesAndProbs(a)
+			bias := s.foodBias(a)
+			best := 4
+			for k := range bias {
+				if bias[k] > bias[best] {
+					best = k
+				}
+			}
+			out = append(out, Demonstration{Tick: s.ticksElapsed, Obs: features, Action: best})
+		}
+		s.mu.Unlock()
+	}
+	return out, nil
+}
+
+// meanStrength is the mean strength of living agents, or that of random
+// founders in an empty world.
+func (s *Sim) meanStrength() float64 {
+	if len(s.agents) == 0 {
+		return 10
+	}
+	sum := 0.0
+	for _, a := range s.sortedAgents() {
+		sum += a.Strength
+	}
+	return sum / float64(len(s.agents))
+}
+
+// NewBrain returns a freshly initialised brain like the ones planted agents get.
+func (s *Sim) NewBrain() Brain {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var a Agent
+	s.initBrain(&a, 0.05)
+	return brainOf(&a)
+}
+
+func (s *Sim) SetTrainedBrain(b Brain, rep TrainReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trained = &b
+	s.emit(Event{Type: EventBrainTrained, Count: rep.Samples})
+}
+
+func (s *Sim) TrainedBrain() (Brain, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.trained == nil {
+		return Brain{}, false
+	}
+	return *s.trained, true
+}
+
+// SeedPlanted makes agents added with AddAgentAt or SpawnAgents start from
+// the trained brain instead of random weights.
+func (s *Sim) SeedPlanted(enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if enabled && s.trained == nil {
+		return ErrNoTrained
+	}
+	s.seedPlanted = enabled
+	return nil
+}
+
+func (s *Sim) plant(a *Agent, brainScale float64) {
+	s.spawn(a, brainScale)
+	if s.seedPlanted && s.trained != nil {
+		s.trained.apply(a)
+	}
+}
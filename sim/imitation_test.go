@@ -0,0 +1,205 @@
+package sim
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// separableDemos labels one-hot observations with the action of the hot
+// feature, a problem softmax regression fits exactly.
+func separableDemos(n int) []Demonstration {
+	actions := []int{0, 2, 4, 6, 8}
+	out := make([]Demonstration, n)
+	for i := range out {
+		obs := make([]float64, numFeatures)
+		obs[i%numFeatures] = 1
+		out[i] = Demonstration{Obs: obs, Action: actions[i%numFeatures]}
+	}
+	return out
+}
+
+func TestTrainBrain(t *testing.T) {
+	tests := []struct {
+		name    string
+		demos   []Demonstration
+		opts    TrainOptions
+		wantErr error
+		minAcc  float64
+	}{
+		{"separable", separableDemos(50), TrainOptions{Epochs: 200, LR: 5}, nil, 1},
+		{"with l2", separableDemos(50), TrainOptions{Epochs: 200, LR: 5, L2: 1e-3}, nil, 1},
+		{"bad rows skipped", append(separableDemos(10), Demonstration{Obs: []float64{1}, Action: 0}, Demonstration{Obs: make([]float64, numFeatures), Action: 99}), TrainOptions{Epochs: 200, LR: 5}, nil, 1},
+		{"no samples", nil, TrainOptions{Epochs: 10, LR: 1}, ErrNoSamples, 0},
+		{"zero epochs", separableDemos(5), TrainOptions{Epochs: 0, LR: 1}, ErrBadTraining, 0},
+		{"too many epochs", separableDemos(5), TrainOptions{Epochs: MaxTrainEpochs + 1, LR: 1}, ErrBadTraining, 0},
+		{"zero lr", separableDemos(5), TrainOptions{Epochs: 10}, ErrBadTraining, 0},
+		{"negative l2", separableDemos(5), TrainOptions{Epochs: 10, LR: 1, L2: -1}, ErrBadTraining, 0},
+		{"over the work cap", separableDemos(MaxTrainWork/MaxTrainEpochs + 1), TrainOptions{Epochs: MaxTrainEpochs, LR: 1}, ErrBadTraining, 0},
+	}
+	base := validBrain()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, rep, err := TrainBrain(base, tt.demos, tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := b.Validate(); err != nil {
+				t.Fatalf("trained brain invalid: %v", err)
+			}
+			if rep.Accuracy < tt.minAcc || !(rep.Loss < math.Log(numActions)) {
+				t.Fatalf("report %+v: accuracy below %v or loss not below uniform", rep, tt.minAcc)
+			}
+			if b.REps != base.REps || b.CriticLR != base.CriticLR {
+				t.Fatalf("learner settings changed: %+v", b)
+			}
+		})
+	}
+}
+
+func TestTrainBrainCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := trainBrain(ctx, validBrain(), separableDemos(10), TrainOptions{Epochs: 10, LR: 1}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+}
+
+func waitJob(t *testing.T, s *Sim) TrainJob {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, _ := s.TrainingJob(); job.State != JobRunning {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("training job did not finish")
+	return TrainJob{}
+}
+
+func lastEvent(s *Sim) Event {
+	events := s.EventsSince(s.LastEventID()-1, 0)
+	return events[len(events)-1]
+}
+
+func TestStartTraining(t *testing.T) {
+	s := testSim(t, 10, 10)
+	if _, ok := s.TrainingJob(); ok {
+		t.Fatal("job before any training")
+	}
+	if _, err := s.StartTraining(nil, DefaultTrainOptions(), "demonstrations"); !errors.Is(err, ErrNoSamples) {
+		t.Fatalf("empty demos: err = %v", err)
+	}
+
+	job, err := s.StartTraining(separableDemos(50), TrainOptions{Epochs: 100, LR: 5}, "heuristic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != 1 || job.State != JobRunning {
+		t.Fatalf("started job = %+v", job)
+	}
+	done := waitJob(t, s)
+	if done.State != JobDone || done.Report == nil || done.Report.Source != "heuristic" || done.Report.Accuracy != 1 {
+		t.Fatalf("finished job = %+v", done)
+	}
+	if _, ok := s.TrainedBrain(); !ok {
+		t.Fatal("no trained brain after the job")
+	}
+	if e := lastEvent(s); e.Type != EventBrainTrained || e.Job != 1 || e.Count != 50 {
+		t.Fatalf("completion event = %+v", e)
+	}
+
+	long, err := s.StartTraining(separableDemos(10000), TrainOptions{Epochs: MaxTrainEpochs, LR: 1}, "heuristic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.StartTraining(separableDemos(5), TrainOptions{Epochs: 1, LR: 1}, "heuristic"); !errors.Is(err, ErrTrainingBusy) {
+		t.Fatalf("second job: err = %v, want ErrTrainingBusy", err)
+	}
+	s.stopTraining()
+	failed, _ := s.TrainingJob()
+	if failed.ID != long.ID || failed.State != JobFailed || failed.Error == "" {
+		t.Fatalf("cancelled job = %+v", failed)
+	}
+	if e := lastEvent(s); e.Type != EventTrainingFailed || e.Job != long.ID {
+		t.Fatalf("failure event = %+v", e)
+	}
+}
+
+func TestHeuristicProbeStrength(t *testing.T) {
+	s := testSim(t, 10, 10)
+	s.mu.Lock()
+	empty := s.meanStrength()
+	s.mu.Unlock()
+	if empty != 10 {
+		t.Fatalf("empty world probe strength = %v", empty)
+	}
+	for i, st := range []float64{4, 8, 30} {
+		if _, err := s.AddAgentAt(i, 0, 50, Male, 0.5, 1, st, 0.1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.mu.Lock()
+	got := s.meanStrength()
+	s.mu.Unlock()
+	if got != 14 {
+		t.Fatalf("probe strength = %v, want 14", got)
+	}
+}
+
+func TestStartHeuristicTraining(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		opts TrainOptions
+	}{
+		{"no samples", 0, TrainOptions{Epochs: 10, LR: 1}},
+		{"too many samples", MaxTrainSamples + 1, TrainOptions{Epochs: 1, LR: 1}},
+		{"over the work cap", MaxTrainSamples, TrainOptions{Epochs: MaxTrainEpochs, LR: 1}},
+		{"bad options", 10, TrainOptions{Epochs: 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testSim(t, 10, 10)
+			if _, err := s.StartHeuristicTraining(tt.n, tt.opts); !errors.Is(err, ErrBadTraining) {
+				t.Fatalf("err = %v, want ErrBadTraining", err)
+			}
+			if job, ok := s.TrainingJob(); ok {
+				t.Fatalf("rejected request started %+v", job)
+			}
+		})
+	}
+
+	s := testSim(t, 20, 20)
+	for i := 0; i < 5; i++ {
+		if err := s.AddFoodAt(i*4, i*3, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := 2*heuristicBatch + 7
+	if _, err := s.StartHeuristicTraining(n, TrainOptions{Epochs: 20, LR: 5}); err != nil {
+		t.Fatal(err)
+	}
+	done := waitJob(t, s)
+	if done.State != JobDone || done.Source != "heuristic" || done.Report == nil || done.Report.Samples != n {
+		t.Fatalf("finished job = %+v", done)
+	}
+
+	long, err := s.StartHeuristicTraining(MaxTrainSamples, TrainOptions{Epochs: MaxTrainWork / MaxTrainSamples, LR: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.stopTraining()
+	if failed, _ := s.TrainingJob(); failed.ID != long.ID || failed.State != JobFailed {
+		t.Fatalf("cancelled job = %+v", failed)
+	}
+}
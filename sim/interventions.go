@@ -0,0 +1,195 @@
+package sim
+
+const CauseRemoved DeathCause = "removed"
+
+const (
+	EventAgentRemoved    EventType = "agent_removed"
+	EventAgentTeleported EventType = "agent_teleported"
+	EventAgentEdited     EventType = "agent_edited"
+	EventAgentCloned     EventType = "agent_cloned"
+	EventBrainReset      EventType = "brain_reset"
+	EventBrainCopied     EventType = "brain_copied"
+	EventFoodRemoved     EventType = "food_removed"
+)
+
+type AgentEdit struct {
+	Energy       *float64 `json:"energy"`
+	Sex          *string  `json:"sex"`
+	Aggression   *float64 `json:"agg"`
+	Speed        *int     `json:"spd"`
+	Strength     *float64 `json:"strength"`
+	Repro        *float64 `json:"repro"`
+	LearningRate *float64 `json:"lr"`
+}
+
+func (s *Sim) KillAgent(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.agents[id]
+	if !ok {
+		return ErrAgentNotFound
+	}
+	s.emit(Event{Type: EventAgentRemoved, ActorID: a.ID, ActorSex: a.Sex, X: a.X, Y: a.Y, Energy: a.Energy})
+	s.removeAgent(a, CauseRemoved, 0)
+	return nil
+}
+
+func (s *Sim) TeleportAgent(id, x, y int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.agents[id]
+	if !ok {
+		return ErrAgentNotFound
+	}
+	if !s.inBounds(x, y) {
+		return ErrOutOfBounds
+	}
+	from := []int{a.X, a.Y}
+	a.X, a.Y = x, y
+	s.emit(Event{Type: EventAgentTeleported, ActorID: a.ID, ActorSex: a.Sex, X: x, Y: y, From: from})
+	return nil
+}
+
+func (s *Sim) EditAgent(id int, e AgentEdit) (AgentView, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.agents[id]
+	if !ok {
+		return AgentView{}, ErrAgentNotFound
+	}
+	var sex Sex
+	if e.Sex != nil {
+		parsed, err := ParseSex(*e.Sex)
+		if err != nil {
+			return AgentView{}, err
+		}
+		sex = parsed
+	}
+	fields := []string{}
+	if e.Energy != nil {
+		a.Energy = *e.Energy
+		fields = append(fields, "energy")
+	}
+	if e.Sex != nil {
+		a.Sex = sex
+		fields = append(fields, "sex")
+	}
+	if e.Aggression != nil {
+		a.Aggression = clampF(*e.Aggression, 0, 1)
+		fields = append(fields, "agg")
+	}
+	if e.Speed != nil {
+		a.Speed = clampInt(*e.Speed, 1, 5)
+		fields = append(fields, "spd")
+	}
+	if e.Strength != nil {
+		a.Strength = *e.Strength
+		fields = append(fields, "strength")
+	}
+	if e.Repro != nil {
+		a.Repro = clampF(*e.Repro, 0, 1)
+		fields = append(fields, "repro")
+	}
+	if e.LearningRate != nil {
+		a.LearningRate = clampF(*e.LearningRate, 0, 1)
+		a.CriticLR = 2 * a.LearningRate
+		fields = append(fields, "lr")
+	}
+	s.emit(Event{Type: EventAgentEdited, ActorID: a.ID, ActorSex: a.Sex, X: a.X, Y: a.Y, Energy: a.Energy, Fields: fields})
+	return viewOf(a), nil
+}
+
+func (s *Sim) CloneAgent(id int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	src, ok := s.agents[id]
+	if !ok {
+		return 0, ErrAgentNotFound
+	}
+	s.nextID++
+	c := &Agent{
+		ID:         s.nextID,
+		X:          src.X,
+		Y:          src.Y,
+		Energy:     src.Energy,
+		Sex:        src.Sex,
+		Aggression: src.Aggression,
+		Speed:      src.Speed,
+		Strength:   src.Strength,
+		Repro:      src.Repro,
+		Experience: map[string]int{},
+		PolicyDir:  4,
+		Parents:    []int{src.ID},
+		Generation: src.Generation,
+		Species:    src.Species,
+	}
+	copyBrain(c, src)
+	s.agents[c.ID] = c
+	s.countBirth(c)
+	s.lineage[c.ID] = c.Parents
+	s.recordBirth(c)
+	s.emit(Event{Type: EventAgentCloned, ActorID: c.ID, ActorSex: c.Sex, TargetID: src.ID, X: c.X, Y: c.Y, Energy: c.Energy})
+	return c.ID, nil
+}
+
+func (s *Sim) ResetBrain(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.agents[id]
+	if !ok {
+		return ErrAgentNotFound
+	}
+	s.initBrain(a, 0.1)
+	s.emit(Event{Type: EventBrainReset, ActorID: a.ID, ActorSex: a.Sex, X: a.X, Y: a.Y})
+	return nil
+}
+
+func (s *Sim) CopyBrain(from, to int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	src, ok := s.agents[from]
+	if !ok {
+		return ErrAgentNotFound
+	}
+	dst, ok := s.agents[to]
+	if !ok {
+		return ErrAgentNotFound
+	}
+	copyBrain(dst, src)
+	s.emit(Event{Type: EventBrainCopied, ActorID: dst.ID, ActorSex: dst.Sex, TargetID: src.ID, X: dst.X, Y: dst.Y})
+	return nil
+}
+
+func (s *Sim) RemoveFoodAt(x, y int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.inBounds(x, y) {
+		return ErrOutOfBounds
+	}
+	key, ok := s.foodAtKey(x, y)
+	if !ok {
+		return ErrFoodNotFound
+	}
+	f := s.foods[key]
+	delete(s.foods, key)
+	s.emit(Event{Type: EventFoodRemoved, X: x, Y: y, Energy: f.Energy, Count: 1})
+	return nil
+}
+
+func (s *Sim) RemoveFoodIn(r Rect) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	energy := 0.0
+	for key, f := range s.foods {
+		if r.Contains(f.X, f.Y) {
+			energy += f.Energy
+			delete(s.foods, key)
+			n++
+		}
+	}
+	if n > 0 {
+		s.emit(Event{Type: EventFoodRemoved, X: r.X, Y: r.Y, Energy: energy, Count: n, Area: &r})
+	}
+	return n
+}
@@ -0,0 +1,72 @@
+package sim
+
+import "math"
+
+const tdWindow = 32
+
+// PolicyView is what an agent's brain made of its last step.
+type PolicyView struct {
+	AgentID     int       `json:"agent_id"`
+	Tick        int       `json:"tick"`
+	Features    []float64 `json:"features"`
+	Probs       []float64 `json:"probs"`
+	Action      int       `json:"action"`
+	Value       float64   `json:"value"`
+	TDErrors    []float64 `json:"td_errors"`
+	RMean       float64   `json:"r_mean"`
+	RVar        float64   `json:"r_var"`
+	WeightNorms []float64 `json:"weight_norms"`
+	WeightNorm  float64   `json:"weight_norm"`
+	CriticNorm  float64   `json:"critic_norm"`
+	LR          float64   `json:"lr"`
+	EntropyBeta float64   `json:"entropy_beta"`
+	AdvClip     float64   `json:"adv_clip"`
+	Controlled  bool      `json:"controlled"`
+}
+
+func (a *Agent) recordTD(delta float64) {
+	if len(a.TDErrors) < tdWindow {
+		a.TDErrors = append(a.TDErrors, delta)
+		return
+	}
+	copy(a.TDErrors, a.TDErrors[1:])
+	a.TDErrors[tdWindow-1] = delta
+}
+
+func norm(v []float64) float64 {
+	return math.Sqrt(dot(v, v))
+}
+
+func (s *Sim) Policy(id int) (PolicyView, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.agents[id]
+	if !ok {
+		return PolicyView{}, false
+	}
+	_, controlled := s.control[id]
+	p := PolicyView{
+		AgentID:     a.ID,
+		Tick:        s.ticksElapsed,
+		Features:    append([]float64(nil), a.LastState...),
+		Probs:       append([]float64(nil), a.LastProbs...),
+		Action:      a.LastAction,
+		Value:       dot(a.CriticW, a.LastState),
+		TDErrors:    append([]float64{}, a.TDErrors...),
+		RMean:       a.RMean,
+		RVar:        a.RVar,
+		WeightNorms: make([]float64, len(a.Weights)),
+		CriticNorm:  norm(a.CriticW),
+		LR:          a.LearningRate,
+		EntropyBeta: a.EntropyBeta,
+		AdvClip:     a.AdvClip,
+		Controlled:  controlled,
+	}
+	sq := 0.0
+	for i, row := range a.Weights {
+		p.WeightNorms[i] = norm(row)
+		sq += p.WeightNorms[i] * p.WeightNorms[i]
+	}
+	p.WeightNorm = math.Sqrt(sq)
+	return p, true
+}
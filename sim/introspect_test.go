@@ -0,0 +1,83 @@
+package sim
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		name  string
+		ticks int
+	}{
+		{"before the first tick", 0},
+		{"after one step", 1},
+		{"after several steps", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No food, so the food bias adds nothing to the logits, and both
+			// agents are held in place so the features stay the same.
+			s := testSim(t, 20, 20)
+			id, err := s.AddAgentAt(10, 10, 150, Female, 0.5, 1, 5, 0.5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			threat, err := s.AddAgentAt(13, 10, 150, Male, 0.5, 1, 15, 0.5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Control(id, threat); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.ticks; i++ {
+				if _, err := s.Step(map[int]int{id: 4, threat: 4}); err != nil {
+					t.Fatal(err)
+				}
+				<-s.StateChan
+			}
+
+			p, ok := s.Policy(id)
+			if !ok {
+				t.Fatal("no policy for a living agent")
+			}
+			if p.AgentID != id || p.Tick != tt.ticks || !p.Controlled {
+				t.Fatalf("policy = %+v", p)
+			}
+			if tt.ticks == 0 {
+				for _, v := range p.Probs {
+					if v != 0 {
+						t.Fatalf("probs before any step = %v, want zeros", p.Probs)
+					}
+				}
+				return
+			}
+			sum := 0.0
+			for _, v := range p.Probs {
+				sum += v
+			}
+			if len(p.Probs) != NumActions || math.Abs(sum-1) > 1e-9 {
+				t.Fatalf("probs %v sum to %v", p.Probs, sum)
+			}
+			s.mu.Lock()
+			features, probs := s.computeFeaturesAndProbs(s.agents[id])
+			s.mu.Unlock()
+			if !reflect.DeepEqual(p.Features, features) || features[4] == 0 {
+				t.Fatalf("features = %v, want %v with a threat", p.Features, features)
+			}
+			for i := range probs {
+				if math.Abs(p.Probs[i]-probs[i]) > 1e-12 {
+					t.Fatalf("probs = %v, want %v", p.Probs, probs)
+				}
+			}
+			if p.Action != 4 {
+				t.Fatalf("action = %d, want 4", p.Action)
+			}
+		})
+	}
+	s := testSim(t, 10, 10)
+	if _, ok := s.Policy(999); ok {
+		t.Fatal("policy for an unknown agent")
+	}
+}
@@ -0,0 +1,205 @@
+package sim
+
+import (
+	"fmt"
+	"strings"
+)
+
+const DefaultLang = "en"
+
+type catalog struct {
+	events map[EventType]func(e Event) string
+	causes map[DeathCause]string
+}
+
+var catalogs = map[string]catalog{
+	"en": {
+		events: map[EventType]func(e Event) string{
+			EventBirth: func(e Event) string {
+				if len(e.Parents) == 2 {
+					return fmt.Sprintf("Agent %d (%s) born to %d and %d", e.ActorID, e.ActorSex, e.Parents[0], e.Parents[1])
+				}
+				return fmt.Sprintf("Agent %d (%s) born", e.ActorID, e.ActorSex)
+			},
+			EventAttack: func(e Event) string {
+				return fmt.Sprintf("Agent %d (%s) attacked %d (damage %.1f)", e.ActorID, e.ActorSex, e.TargetID, e.Damage)
+			},
+			EventKill: func(e Event) string {
+				return fmt.Sprintf("Agent %d (%s) killed %d", e.ActorID, e.ActorSex, e.TargetID)
+			},
+			EventMerge: func(e Event) string {
+				return fmt.Sprintf("Agent %d (%s) merged with %d", e.ActorID, e.ActorSex, e.TargetID)
+			},
+			EventFoodEaten: func(e Event) string {
+				return fmt.Sprintf("Agent %d (%s) ate food (%.1f)", e.ActorID, e.ActorSex, e.Energy)
+			},
+			EventFoodAdded: func(e Event) string {
+				return fmt.Sprintf("Food added at (%d, %d)", e.X, e.Y)
+			},
+			EventAgentSpawned: func(e Event) string {
+				return fmt.Sprintf("Agent %d (%s) spawned", e.ActorID, e.ActorSex)
+			},
+			EventAgentRemoved: func(e Event) string {
+				return fmt.Sprintf("Agent %d (%s) removed by operator", e.ActorID, e.ActorSex)
+			},
+			EventAgentTeleported: func(e Event) string {
+				return fmt.Sprintf("Agent %d (%s) teleported to (%d, %d)", e.ActorID, e.ActorSex, e.X, e.Y)
+			},
+			EventAgentEdited: func(e Event) string {
+				return fmt.Sprintf("Agent %d (%s) edited: %s", e.ActorID, e.ActorSex, strings.Join(e.Fields, ", "))
+			},
+			EventAgentCloned: func(e Event) string {
+				return fmt.Sprintf("Agent %d (%s) cloned from %d", e.ActorID, e.ActorSex, e.TargetID)
+			},
+			EventBrainReset: func(e Event) string {
+				return fmt.Sprintf("Agent %d (%s) brain reset", e.ActorID, e.ActorSex)
+			},
+			EventBrainCopied: func(e Event) string {
+				return fmt.Sprintf("Agent %d (%s) received brain of %d", e.ActorID, e.ActorSex, e.TargetID)
+			},
+			EventFoodRemoved: func(e Event) string {
+				if e.Area != nil {
+					return fmt.Sprintf("%d food removed from %dx%d area at (%d, %d)", e.Count, e.Area.W, e.Area.H, e.Area.X, e.Area.Y)
+				}
+				return fmt.Sprintf("Food removed at (%d, %d)", e.X, e.Y)
+			},
+			EventFoodPainted: func(e Event) string {
+				return fmt.Sprintf("%d food painted in %dx%d area at (%d, %d)", e.Count, e.Area.W, e.Area.H, e.Area.X, e.Area.Y)
+			},
+			EventAgentsSpawned: func(e Event) string {
+				return fmt.Sprintf("%d agents spawned in %dx%d area at (%d, %d)", e.Count, e.Area.W, e.Area.H, e.Area.X, e.Area.Y)
+			},
+			EventWorldReset: func(e Event) string {
+				return fmt.Sprintf("World reset to %dx%d with %d agents", e.Area.W, e.Area.H, e.Count)
+			},
+			EventAgentPossessed: func(e Event) string {
+				return fmt.Sprintf("Agent %d (%s) taken over by a player", e.ActorID, e.ActorSex)
+			},
+			EventAgentReleased: func(e Event) string {
+				return fmt.Sprintf("Agent %d (%s) back on its own policy", e.ActorID, e.ActorSex)
+			},
+			EventBrainTrained: func(e Event) string {
+				return fmt.Sprintf("Brain trained on %d samples", e.Count)
+			},
+			EventTrainingFailed: func(e Event) string {
+				return fmt.Sprintf("Training job %d failed", e.Job)
+			},
+			EventBrainLoaded: func(e Event) string {
+				return fmt.Sprintf("Agent %d (%s) loaded a saved brain", e.ActorID, e.ActorSex)
+			},
+		},
+		causes: map[DeathCause]string{
+			CauseStarved:     "Agent %d (%s) starved to death at age %d",
+			CauseOldAge:      "Agent %d (%s) died of old age at age %d",
+			CauseKilled:      "Agent %d (%s) was killed at age %d",
+			CauseMerged:      "Agent %d (%s) was absorbed in a merge at age %d",
+			CauseRemoved:     "Agent %d (%s) was removed at age %d",
+			CauseQuarantined: "Agent %d (%s) was quarantined at age %d: its brain diverged",
+		},
+	},
+	"ru": {
+		events: map[EventType]func(e Event) string{
+			EventBirth: func(e Event) string {
+				if len(e.Parents) == 2 {
+					return fmt.Sprintf("Рождён агент %d (%s) из %d и %d", e.ActorID, e.ActorSex, e.Parents[0], e.Parents[1])
+				}
+				return fmt.Sprintf("Рождён агент %d (%s)", e.ActorID, e.ActorSex)
+			},
+			EventAttack: func(e Event) string {
+				return fmt.Sprintf("Агент %d (%s) атаковал %d (урон %.1f)", e.ActorID, e.ActorSex, e.TargetID, e.Damage)
+			},
+			EventKill: func(e Event) string {
+				return fmt.Sprintf("Агент %d (%s) убил %d", e.ActorID, e.ActorSex, e.TargetID)
+			},
+			EventMerge: func(e Event) string {
+				return fmt.Sprintf("Агент %d (%s) слился с %d", e.ActorID, e.ActorSex, e.TargetID)
+			},
+			EventFoodEaten: func(e Event) string {
+				return fmt.Sprintf("Агент %d (%s) съел еду (%.1f)", e.ActorID, e.ActorSex, e.Energy)
+			},
+			EventFoodAdded: func(e Event) string {
+				return fmt.Sprintf("Добавлена еда в (%d, %d)", e.X, e.Y)
+			},
+			EventAgentSpawned: func(e Event) string {
+				return fmt.Sprintf("Появился агент %d (%s)", e.ActorID, e.ActorSex)
+			},
+			EventAgentRemoved: func(e Event) string {
+				return fmt.Sprintf("Агент %d (%s) удалён оператором", e.ActorID, e.ActorSex)
+			},
+			EventAgentTeleported: func(e Event) string {
+				return fmt.Sprintf("Агент %d (%s) перемещён в (%d, %d)", e.ActorID, e.ActorSex, e.X, e.Y)
+			},
+			EventAgentEdited: func(e Event) string {
+				return fmt.Sprintf("Агент %d (%s) изменён: %s", e.ActorID, e.ActorSex, strings.Join(e.Fields, ", "))
+			},
+			EventAgentCloned: func(e Event) string {
+				return fmt.Sprintf("Агент %d (%s) клонирован из %d", e.ActorID, e.ActorSex, e.TargetID)
+			},
+			EventBrainReset: func(e Event) string {
+				return fmt.Sprintf("Мозг агента %d (%s) сброшен", e.ActorID, e.ActorSex)
+			},
+			EventBrainCopied: func(e Event) string {
+				return fmt.Sprintf("Агент %d (%s) получил мозг агента %d", e.ActorID, e.ActorSex, e.TargetID)
+			},
+			EventFoodRemoved: func(e Event) string {
+				if e.Area != nil {
+					return fmt.Sprintf("Удалено еды: %d в области %dx%d у (%d, %d)", e.Count, e.Area.W, e.Area.H, e.Area.X, e.Area.Y)
+				}
+				return fmt.Sprintf("Удалена еда в (%d, %d)", e.X, e.Y)
+			},
+			EventFoodPainted: func(e Event) string {
+				return fmt.Sprintf("Добавлено еды: %d в области %dx%d у (%d, %d)", e.Count, e.Area.W, e.Area.H, e.Area.X, e.Area.Y)
+			},
+			EventAgentsSpawned: func(e Event) string {
+				return fmt.Sprintf("Появилось агентов: %d в области %dx%d у (%d, %d)", e.Count, e.Area.W, e.Area.H, e.Area.X, e.Area.Y)
+			},
+			EventWorldReset: func(e Event) string {
+				return fmt.Sprintf("Мир пересоздан: %dx%d, агентов: %d", e.Area.W, e.Area.H, e.Count)
+			},
+			EventAgentPossessed: func(e Event) string {
+				return fmt.Sprintf("Агентом %d (%s) управляет игрок", e.ActorID, e.ActorSex)
+			},
+			EventAgentReleased: func(e Event) string {
+				return fmt.Sprintf("Агент %d (%s) снова действует сам", e.ActorID, e.ActorSex)
+			},
+			EventBrainTrained: func(e Event) string {
+				return fmt.Sprintf("Мозг обучен на %d примерах", e.Count)
+			},
+			EventTrainingFailed: func(e Event) string {
+				return fmt.Sprintf("Задача обучения %d не удалась", e.Job)
+			},
+			EventBrainLoaded: func(e Event) string {
+				return fmt.Sprintf("Агент %d (%s) получил сохранённый мозг", e.ActorID, e.ActorSex)
+			},
+		},
+		causes: map[DeathCause]string{
+			CauseStarved:     "Агент %d (%s) умер от голода в возрасте %d",
+			CauseOldAge:      "Агент %d (%s) умер от старости в возрасте %d",
+			CauseKilled:      "Агент %d (%s) погиб в возрасте %d",
+			CauseMerged:      "Агент %d (%s) поглощён при слиянии в возрасте %d",
+			CauseRemoved:     "Агент %d (%s) удалён в возрасте %d",
+			CauseQuarantined: "Агент %d (%s) изолирован в возрасте %d: его мозг разошёлся",
+		},
+	},
+}
+
+func SupportedLang(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+func RenderEvent(e Event, lang string) string {
+	c, ok := catalogs[lang]
+	if !ok {
+		c = catalogs[DefaultLang]
+	}
+	if e.Type == EventDeath {
+		if f, ok := c.causes[e.Cause]; ok {
+			return fmt.Sprintf(f, e.ActorID, e.ActorSex, e.Age)
+		}
+	}
+	if f, ok := c.events[e.Type]; ok {
+		return f(e)
+	}
+	return fmt.Sprintf("%s: %d", e.Type, e.ActorID)
+}
@@ -1,9 +1,10 @@
 package sim
 
 import (
-	"fmt"
+	"context"
 	"math"
 	"math/rand"
+	"sort"
 	"sync"
 	"time"
 )
@@ -17,7 +18,8 @@ const (
 
 type Agent struct {
 	ID           int            `json:"id"`
-	X, Y         int            `json:"x"`
+	X            int            `json:"x"`
+	Y            int            `json:"y"`
 	Energy       float64        `json:"energy"`
 	Sex          Sex            `json:"sex"`
 	Age          int            `json:"age"`
@@ -33,6 +35,8 @@ type Agent struct {
 	LastProbs    []float64      `json:"-"`
 	PolicyDir    int            `json:"policy_dir"`
 	Parents      []int          `json:"parents"`
+	Generation   int            `json:"gen"`
+	Species      int            `json:"species"`
 	Hunger       int            `json:"-"`
 
 	CriticW     []float64 `json:"-"`
@@ -45,22 +49,20 @@ type Agent struct {
 	RVar      float64 `json:"-"`
 	REstAlpha float64 `json:"-"`
 	REps      float64 `json:"-"`
+
+	TDErrors []float64 `json:"-"`
+
+	// life is the life-table row the agent was born into. Merges and edits
+	// change Sex and Generation later, so deaths are counted here instead.
+	life lifeKey
 }
 
 type Food struct {
-	X, Y   int     `json:"x"`
+	X      int     `json:"x"`
+	Y      int     `json:"y"`
 	Energy float64 `json:"energy"`
 }
 
-type Event struct {
-	Type     string `json:"type"`
-	Tick     int    `json:"tick"`
-	ActorID  int    `json:"actor_id"`
-	ActorSex Sex    `json:"actor_sex"`
-	TargetID int    `json:"target_id,omitempty"`
-	Message  string `json:"message"`
-}
-
 type Sim struct {
 	W, H int
 
@@ -79,27 +81,82 @@ type Sim struct {
 	RandomFood      bool
 	RandomFoodProb  float64
 	ticksElapsed    int
-	events          []Event
+	events          eventLog
+	histories       map[int]*AgentHistory
+	deadHistory     []int
+	demo            demographics
+	series          []*series
+	MaxAge          int
+	TraitStatsEvery int
+	tel             telemetry
+	lastState       map[string]interface{}
+	paused          bool
+	tickEvery       time.Duration
+	seed            int64
+	initialAgents   int
+	epoch           int
+	control         map[int]*control
+	inputWait       time.Duration
+	inputReady      chan struct{}
+	demos           []Demonstration
+	trained         *Brain
+	seedPlanted     bool
+	learn           learning
+	order           []*Agent
+	train           training
 }
 
 func NewSim(w, h int) *Sim {
+	cfg := DefaultConfig()
+	cfg.Width, cfg.Height = w, h
+	return NewSimWithConfig(cfg)
+}
+
+func NewSimWithConfig(cfg Config) *Sim {
 	s := &Sim{
-		W: w, H: h,
-		agents:         make(map[int]*Agent),
-		foods:          make(map[int]*Food),
-		StateChan:      make(chan interface{}, 10),
-		rand:           rand.New(rand.NewSource(time.Now().UnixNano())),
-		lineage:        make(map[int][]int),
-		RandomFood:     true,
-		RandomFoodProb: 0.04,
-		events:         make([]Event, 0),
-	}
-	for i := 0; i < 2; i++ {
-		s.addRandomAgent()
+		StateChan:  make(chan interface{}, 10),
+		inputReady: make(chan struct{}, 1),
+		events:     newEventLog(),
+		tel:        newTelemetry(),
 	}
+	s.reset(cfg)
 	return s
 }
 
+func (s *Sim) reset(cfg Config) {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	s.W, s.H = cfg.Width, cfg.Height
+	s.agents = make(map[int]*Agent)
+	s.foods = make(map[int]*Food)
+	s.rand = rand.New(rand.NewSource(seed))
+	s.seed = seed
+	s.totalDeaths, s.totalAgeAtDeath, s.totalBirths = 0, 0, 0
+	s.lineage = make(map[int][]int)
+	s.RandomFood = cfg.RandomFood
+	s.RandomFoodProb = cfg.RandomFoodProb
+	s.ticksElapsed = 0
+	s.histories = make(map[int]*AgentHistory)
+	s.deadHistory = nil
+	s.demo = newDemographics()
+	s.series = newSeries()
+	s.MaxAge = cfg.MaxAge
+	s.TraitStatsEvery = cfg.TraitStatsEvery
+	s.lastState = nil
+	s.paused = cfg.Paused
+	s.tickEvery = time.Duration(cfg.TickMillis) * time.Millisecond
+	s.initialAgents = cfg.InitialAgents
+	s.control = make(map[int]*control)
+	s.inputWait = time.Duration(cfg.InputWaitMillis) * time.Millisecond
+	s.demos = nil
+	s.learn = learning{}
+	for i := 0; i < cfg.InitialAgents; i++ {
+		s.addRandomAgent()
+	}
+}
+
 func (s *Sim) addRandomAgent() {
 	s.nextID++
 	a := &Agent{
@@ -115,57 +172,54 @@ func (s *Sim) addRandomAgent() {
 		Repro:      s.rand.Float64()*0.35 + 0.3,
 		Experience: map[string]int{},
 	}
-	na := 9
-	nf := 5
-	a.Weights = make([][]float64, na)
-	for i := 0; i < na; i++ {
-		a.Weights[i] = make([]float64, nf)
-		for j := 0; j < nf; j++ {
-			a.Weights[i][j] = s.rand.NormFloat64() * 0.1
-		}
-	}
-	a.LearningRate = 0.03
-	a.LastState = make([]float64, nf)
-	a.LastProbs = make([]float64, na)
-	a.CriticW = make([]float64, nf)
-	a.Gamma = 0.98
-	a.CriticLR = 2 * a.LearningRate
-	a.EntropyBeta = 0.01
-	a.AdvClip = 6.0
-	a.REstAlpha = 0.01
-	a.REps = 1e-8
+	s.spawn(a, 0.1)
+}
+
+func (s *Sim) spawn(a *Agent, brainScale float64) {
+	s.initBrain(a, brainScale)
 	a.PolicyDir = 4
 	a.Hunger = 0
+	a.Species = a.ID
 	s.agents[a.ID] = a
-	s.totalBirths++
+	s.countBirth(a)
 	s.lineage[a.ID] = []int{}
+	s.recordBirth(a)
+	s.emit(Event{Type: EventAgentSpawned, ActorID: a.ID, ActorSex: a.Sex, X: a.X, Y: a.Y, Energy: a.Energy})
 }
 
-func (s *Sim) addEvent(eventType string, actorID int, actorSex Sex, targetID int, message string) {
-	if len(s.events) > 5000 {
-		s.events = s.events[1:]
+func (s *Sim) Run(ctx context.Context) {
+	defer s.stopTraining()
+	every := s.tickInterval()
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.Paused() {
+				s.awaitInput(ctx)
+				s.Tick()
+				s.pruneControl()
+			}
+			if d := s.tickInterval(); d != every {
+				every = d
+				ticker.Reset(d)
+			}
+		}
 	}
-	s.events = append(s.events, Event{
-		Type:     eventType,
-		Tick:     s.ticksElapsed,
-		ActorID:  actorID,
-		ActorSex: actorSex,
-		TargetID: targetID,
-		Message:  message,
-	})
 }
 
-func (s *Sim) Run() {
-	ticker := time.NewTicker(200 * time.Millisecond)
-	defer ticker.Stop()
-	for range ticker.C {
-		s.Tick()
-	}
+func (s *Sim) tickInterval() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tickEvery
 }
 
 func (s *Sim) Tick() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.observeTick(time.Now())
 
 	s.ticksElapsed++
 
@@ -181,7 +235,11 @@ func (s *Sim) Tick() {
 		}
 	}
 
-	for id, a := range s.agents {
+	s.order = s.sortedAgents()
+	for _, a := range s.order {
+		if !s.alive(a) {
+			continue
+		}
 		a.Age++
 		a.Energy -= 0.08
 		a.Hunger++
@@ -189,10 +247,11 @@ func (s *Sim) Tick() {
 			a.Energy -= 0.15
 		}
 		if a.Energy <= 0 {
-			s.totalDeaths++
-			s.totalAgeAtDeath += a.Age
-			s.addEvent("death", a.ID, a.Sex, 0, fmt.Sprintf("Агент %d (%s) умер от голода в возрасте %d", a.ID, a.Sex, a.Age))
-			delete(s.agents, id)
+			s.removeAgent(a, CauseStarved, 0)
+			continue
+		}
+		if s.MaxAge > 0 && a.Age > s.MaxAge {
+			s.removeAgent(a, CauseOldAge, 0)
 			continue
 		}
 
@@ -201,6 +260,7 @@ func (s *Sim) Tick() {
 		oldRepro := a.Experience["repro"]
 
 		features, probs, act := s.chooseAction(a)
+		act, external := s.takeAction(a, act)
 		a.LastState = features
 		a.LastProbs = probs
 		a.LastAction = act
@@ -220,6 +280,8 @@ func (s *Sim) Tick() {
 		if fkey, ok := s.foodAtKey(a.X, a.Y); ok {
 			f := s.foods[fkey]
 			a.Energy += f.Energy
+			s.recordLifeEvent(a.ID, LifeEvent{Kind: "ate", X: f.X, Y: f.Y, Amount: f.Energy})
+			s.emit(Event{Type: EventFoodEaten, ActorID: a.ID, ActorSex: a.Sex, X: f.X, Y: f.Y, Energy: f.Energy})
 			delete(s.foods, fkey)
 			a.Experience["ate"]++
 			a.Hunger = 0
@@ -231,41 +293,39 @@ func (s *Sim) Tick() {
 		_ = s.tryReproduce(a)
 
 		if _, exists := s.agents[a.ID]; !exists {
+			if external {
+				s.recordDemo(Demonstration{Tick: s.ticksElapsed, AgentID: a.ID, Obs: features, Action: act, Done: true})
+			}
 			continue
 		}
+		s.recordStep(a)
 
 		reward := (a.Energy - oldEnergy)
 		reward += float64(a.Experience["kills"]-oldKills) * 5.0
 		reward += float64(a.Experience["repro"]-oldRepro) * 3.0
 		reward += distReward * 1.5
-		s.updateActorCritic(a, features, probs, act, reward)
+		s.rewardControlled(a.ID, reward)
+		if external {
+			s.recordDemo(Demonstration{Tick: s.ticksElapsed, AgentID: a.ID, Obs: features, Action: act, Reward: reward})
+		} else {
+			s.updateActorCritic(a, features, probs, act, reward)
+		}
 	}
+	s.quarantineDiverged()
 
-	agentsList := make([]*Agent, 0, len(s.agents))
-	for _, a := range s.agents {
-		agentsList = append(agentsList, a)
-	}
-	agentsOut := make([]map[string]interface{}, 0, len(agentsList))
+	agentsList := s.sortedAgents()
+	s.order = nil
+	learn := s.learningStats(agentsList)
+	s.recordSample(agentsList, learn)
+	s.rollRates()
+
+	agentsOut := make([]AgentView, 0, len(agentsList))
 	for _, a := range agentsList {
-		agentsOut = append(agentsOut, map[string]interface{}{
-			"id":         a.ID,
-			"x":          a.X,
-			"y":          a.Y,
-			"energy":     a.Energy,
-			"age":        a.Age,
-			"sex":        a.Sex,
-			"spd":        a.Speed,
-			"agg":        a.Aggression,
-			"repro":      a.Repro,
-			"exp":        a.Experience,
-			"parents":    a.Parents,
-			"strength":   a.Strength,
-			"policy_dir": a.PolicyDir,
-		})
-	}
-	foodsOut := make([]map[string]interface{}, 0, len(s.foods))
+		agentsOut = append(agentsOut, viewOf(a))
+	}
+	foodsOut := make([]Food, 0, len(s.foods))
 	for _, f := range s.foods {
-		foodsOut = append(foodsOut, map[string]interface{}{"x": f.X, "y": f.Y, "energy": f.Energy})
+		foodsOut = append(foodsOut, *f)
 	}
 	sumAgg := 0.0
 	for _, a := range agentsList {
@@ -286,24 +346,28 @@ func (s *Sim) Tick() {
 		"births":         s.totalBirths,
 		"deaths":         s.totalDeaths,
 		"avg_life":       avgLife,
+		"learning":       learn,
+	}
+	for k, v := range s.demographicMetrics(agentsList) {
+		metrics[k] = v
+	}
+
+	lineage := make(map[int][]int, len(s.lineage))
+	for k, v := range s.lineage {
+		lineage[k] = append([]int(nil), v...)
 	}
 
-	eventsOut := make([]map[string]interface{}, 0, len(s.events))
-	for _, e := range s.events {
-		eventsOut = append(eventsOut, map[string]interface{}{
-			"type":      e.Type,
-			"actor_id":  e.ActorID,
-			"actor_sex": e.ActorSex,
-			"target_id": e.TargetID,
-			"message":   e.Message,
-		})
+	snapshot := map[string]interface{}{"type": "state", "tick": s.ticksElapsed, "agents": agentsOut, "foods": foodsOut, "metrics": metrics, "lineage": lineage, "last_event_id": s.events.nextID - 1, "epoch": s.epoch}
+	if s.TraitStatsEvery > 0 && s.ticksElapsed%s.TraitStatsEvery == 0 {
+		snapshot["traits"] = s.traitStats(agentsList)
 	}
 
-	snapshot := map[string]interface{}{"type": "state", "agents": agentsOut, "foods": foodsOut, "metrics": metrics, "lineage": s.lineage, "events": eventsOut}
+	s.lastState = snapshot
 
 	select {
 	case s.StateChan <- snapshot:
 	default:
+		s.tel.droppedStates++
 	}
 }
 
@@ -319,22 +383,10 @@ func (s *Sim) avgEnergy(list []*Agent) float64 {
 }
 
 func (s *Sim) chooseAction(a *Agent) ([]float64, []float64, int) {
-	var fx, fy int
-	found := false
-	bestD := math.MaxFloat64
-	for _, f := range s.foods {
-		d := math.Abs(float64(f.X-a.X)) + math.Abs(float64(f.Y-a.Y))
-		if d < bestD {
-			bestD = d
-			fx = f.X
-			fy = f.Y
-			found = true
-		}
-	}
 	var dxNorm, dyNorm float64
-	if found {
-		dxNorm = float64(fx-a.X) / float64(s.W)
-		dyNorm = float64(fy-a.Y) / float64(s.H)
+	if f := s.nearestFood(a.X, a.Y); f != nil {
+		dxNorm = float64(f.X-a.X) / float64(s.W)
+		dyNorm = float64(f.Y-a.Y) / float64(s.H)
 	} else {
 		dxNorm = 0
 		dyNorm = 0
@@ -373,25 +425,9 @@ func (s *Sim) chooseAction(a *Agent) ([]float64, []float64, int) {
 		}
 		logits[i] = sum
 	}
-	oldDist := s.distanceToNearestFood(a)
-	biasScale := 3.0
+	bias := s.foodBias(a)
 	for i := 0; i < na; i++ {
-		ddx := (i % 3) - 1
-		ddy := (i / 3) - 1
-		nx := clamp(a.X+ddx, 0, s.W-1)
-		ny := clamp(a.Y+ddy, 0, s.H-1)
-		best := math.MaxFloat64
-		for _, f := range s.foods {
-			d := math.Abs(float64(f.X-nx)) + math.Abs(float64(f.Y-ny))
-			if d < best {
-				best = d
-			}
-		}
-		if best == math.MaxFloat64 {
-			best = float64(s.W + s.H)
-		}
-		heuristic := (oldDist - best) * biasScale
-		logits[i] += heuristic
+		logits[i] += bias[i]
 		if logits[i] > maxl {
 			maxl = logits[i]
 		}
@@ -421,6 +457,31 @@ func (s *Sim) chooseAction(a *Agent) ([]float64, []float64, int) {
 	return features, probs, act
 }
 
+// foodBias scores each move by how much closer it brings a to the nearest food.
+func (s *Sim) foodBias(a *Agent) []float64 {
+	oldDist := s.distanceToNearestFood(a)
+	biasScale := 3.0
+	out := make([]float64, numActions)
+	for i := range out {
+		ddx := (i % 3) - 1
+		ddy := (i / 3) - 1
+		nx := clamp(a.X+ddx, 0, s.W-1)
+		ny := clamp(a.Y+ddy, 0, s.H-1)
+		best := math.MaxFloat64
+		for _, f := range s.foods {
+			d := math.Abs(float64(f.X-nx)) + math.Abs(float64(f.Y-ny))
+			if d < best {
+				best = d
+			}
+		}
+		if best == math.MaxFloat64 {
+			best = float64(s.W + s.H)
+		}
+		out[i] = (oldDist - best) * biasScale
+	}
+	return out
+}
+
 func dot(a, b []float64) float64 {
 	n := len(a)
 	if len(b) < n {
@@ -434,22 +495,10 @@ func dot(a, b []float64) float64 {
 }
 
 func (s *Sim) computeFeaturesAndProbs(a *Agent) ([]float64, []float64) {
-	var fx, fy int
-	found := false
-	bestD := math.MaxFloat64
-	for _, f := range s.foods {
-		d := math.Abs(float64(f.X-a.X)) + math.Abs(float64(f.Y-a.Y))
-		if d < bestD {
-			bestD = d
-			fx = f.X
-			fy = f.Y
-			found = true
-		}
-	}
 	var dxNorm, dyNorm float64
-	if found {
-		dxNorm = float64(fx-a.X) / float64(s.W)
-		dyNorm = float64(fy-a.Y) / float64(s.H)
+	if f := s.nearestFood(a.X, a.Y); f != nil {
+		dxNorm = float64(f.X-a.X) / float64(s.W)
+		dyNorm = float64(f.Y-a.Y) / float64(s.H)
 	} else {
 		dxNorm = 0
 		dyNorm = 0
@@ -518,6 +567,8 @@ func (s *Sim) updateActorCritic(a *Agent, features []float64, probs []float64, a
 	Vnext := dot(a.CriticW, nextFeatures)
 
 	delta := rhat + a.Gamma*Vnext - V
+	a.recordTD(delta)
+	s.learn.observe(delta, a.AdvClip)
 	if delta > a.AdvClip {
 		delta = a.AdvClip
 	}
@@ -567,6 +618,25 @@ func (s *Sim) foodAtKey(x, y int) (int, bool) {
 	return k, ok
 }
 
+// sortedAgents lists living agents by ID. Tick walks agents in this order
+// rather than map order, so a seed replays the same run.
+func (s *Sim) sortedAgents() []*Agent {
+	ids := make([]int, 0, len(s.agents))
+	for id := range s.agents {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	out := make([]*Agent, len(ids))
+	for i, id := range ids {
+		out[i] = s.agents[id]
+	}
+	return out
+}
+
+func (s *Sim) alive(a *Agent) bool {
+	return s.agents[a.ID] == a
+}
+
 func (s *Sim) agentAt(x, y int) bool {
 	for _, a := range s.agents {
 		if a.X == x && a.Y == y {
@@ -585,6 +655,20 @@ func (s *Sim) randomMove(a *Agent) {
 	a.Y = ny
 }
 
+// nearestFood breaks distance ties by cell key so the choice does not depend
+// on map iteration order.
+func (s *Sim) nearestFood(x, y int) *Food {
+	var best *Food
+	bestD, bestKey := 0, 0
+	for key, f := range s.foods {
+		d := abs(f.X-x) + abs(f.Y-y)
+		if best == nil || d < bestD || (d == bestD && key < bestKey) {
+			best, bestD, bestKey = f, d, key
+		}
+	}
+	return best
+}
+
 func (s *Sim) distanceToNearestFood(a *Agent) float64 {
 	best := math.MaxFloat64
 	for _, f := range s.foods {
@@ -601,21 +685,11 @@ func (s *Sim) distanceToNearestFood(a *Agent) float64 {
 
 func (s *Sim) moveTowardsFood(a *Agent) bool {
 	sight := 6
-	bestDist := math.MaxFloat64
-	var fx, fy int
-	found := false
-	for _, f := range s.foods {
-		d := math.Abs(float64(f.X-a.X)) + math.Abs(float64(f.Y-a.Y))
-		if int(d) <= sight && d < bestDist {
-			bestDist = d
-			fx = f.X
-			fy = f.Y
-			found = true
-		}
-	}
-	if !found {
+	f := s.nearestFood(a.X, a.Y)
+	if f == nil || abs(f.X-a.X)+abs(f.Y-a.Y) > sight {
 		return false
 	}
+	fx, fy := f.X, f.Y
 	dx := 0
 	dy := 0
 	if fx > a.X {
@@ -639,8 +713,8 @@ func (s *Sim) tryAttack(a *Agent) bool {
 	if a.Sex == Female {
 		return false
 	}
-	for _, other := range s.agents {
-		if other.ID == a.ID {
+	for _, other := range s.order {
+		if other.ID == a.ID || !s.alive(other) {
 			continue
 		}
 		if other.Sex == Female {
@@ -653,12 +727,13 @@ func (s *Sim) tryAttack(a *Agent) bool {
 				other.Energy -= damage
 				a.Experience["attacks"]++
 				a.Energy += damage * 0.1
-				s.addEvent("attack", a.ID, a.Sex, other.ID, fmt.Sprintf("Агент %d (%s) атаковал %d (урон %.1f)", a.ID, a.Sex, other.ID, damage))
+				s.emit(Event{Type: EventAttack, ActorID: a.ID, ActorSex: a.Sex, TargetID: other.ID, X: other.X, Y: other.Y, Damage: damage})
+				s.recordLifeEvent(a.ID, LifeEvent{Kind: "attack_given", OtherID: other.ID, X: other.X, Y: other.Y, Amount: damage})
+				s.recordLifeEvent(other.ID, LifeEvent{Kind: "attack_received", OtherID: a.ID, X: other.X, Y: other.Y, Amount: damage})
 				if other.Energy <= 0 {
-					s.totalDeaths++
-					s.totalAgeAtDeath += other.Age
-					s.addEvent("kill", a.ID, a.Sex, other.ID, fmt.Sprintf("Агент %d (%s) убил %d", a.ID, a.Sex, other.ID))
-					delete(s.agents, other.ID)
+					s.emit(Event{Type: EventKill, ActorID: a.ID, ActorSex: a.Sex, TargetID: other.ID, X: other.X, Y: other.Y})
+					s.recordLifeEvent(a.ID, LifeEvent{Kind: "kill", OtherID: other.ID, X: other.X, Y: other.Y})
+					s.removeAgent(other, CauseKilled, a.ID)
 					a.Experience["kills"]++
 				}
 				return true
@@ -669,8 +744,8 @@ func (s *Sim) tryAttack(a *Agent) bool {
 }
 
 func (s *Sim) tryReproduce(a *Agent) bool {
-	for _, other := range s.agents {
-		if other.ID == a.ID {
+	for _, other := range s.order {
+		if other.ID == a.ID || !s.alive(other) {
 			continue
 		}
 		if other.Sex == a.Sex {
@@ -686,8 +761,10 @@ func (s *Sim) tryReproduce(a *Agent) bool {
 				child.Sex = []Sex{Male, Female}[s.rand.Intn(2)]
 				child.Age = 0
 				child.Parents = []int{a.ID, other.ID}
+				child.Generation = max(a.Generation, other.Generation) + 1
+				child.Species = a.Species
 				s.lineage[child.ID] = child.Parents
-				s.totalBirths++
+				s.countBirth(child)
 				child.Aggression = clampF((a.Aggression+other.Aggression)/2+(s.rand.NormFloat64()*0.05), 0, 1)
 				child.Speed = clampInt((a.Speed+other.Speed)/2+int(s.rand.NormFloat64()*0.5), 1, 3)
 				child.Repro = clampF((a.Repro+other.Repro)/2+s.rand.NormFloat64()*0.01, 0, 1)
@@ -718,12 +795,14 @@ func (s *Sim) tryReproduce(a *Agent) bool {
 					}
 				}
 				child.LearningRate = (a.LearningRate + other.LearningRate) / 2
+				inheritLearner(child, a, other, nf)
 				child.LastState = make([]float64, nf)
 				child.LastProbs = make([]float64, na)
 				child.PolicyDir = 4
 				child.Hunger = 0
 				s.agents[child.ID] = child
-				s.addEvent("birth", child.ID, child.Sex, 0, fmt.Sprintf("Рождён агент %d (%s) из %d и %d", child.ID, child.Sex, a.ID, other.ID))
+				s.recordBirth(child)
+				s.emit(Event{Type: EventBirth, ActorID: child.ID, ActorSex: child.Sex, X: child.X, Y: child.Y, Energy: child.Energy, Parents: child.Parents})
 				a.Energy *= 0.85
 				other.Energy *= 0.85
 				a.Experience["repro"]++
@@ -739,8 +818,8 @@ func (s *Sim) tryReproduce(a *Agent) bool {
 }
 
 func (s *Sim) tryMerge(a *Agent) bool {
-	for _, other := range s.agents {
-		if other.ID == a.ID {
+	for _, other := range s.order {
+		if other.ID == a.ID || !s.alive(other) {
 			continue
 		}
 		if other.Sex != a.Sex {
@@ -763,6 +842,7 @@ func (s *Sim) tryMerge(a *Agent) bool {
 				a.Strength = a.Strength*wa + other.Strength*wb + 0.5
 				a.Repro = clampF(a.Repro*wa+other.Repro*wb, 0, 1)
 				a.Speed = clampInt(max(a.Speed, other.Speed), 1, 5)
+				a.Generation = max(a.Generation, other.Generation)
 				for k, v := range other.Experience {
 					a.Experience[k] += v
 				}
@@ -795,8 +875,9 @@ func (s *Sim) tryMerge(a *Agent) bool {
 
 				a.Parents = append(a.Parents, other.ID)
 				s.lineage[a.ID] = a.Parents
-				s.addEvent("merge", a.ID, a.Sex, other.ID, fmt.Sprintf("Агент %d (%s) слился с %d", a.ID, a.Sex, other.ID))
-				delete(s.agents, other.ID)
+				s.emit(Event{Type: EventMerge, ActorID: a.ID, ActorSex: a.Sex, TargetID: other.ID, X: a.X, Y: a.Y, Energy: a.Energy})
+				s.recordLifeEvent(a.ID, LifeEvent{Kind: "merge", OtherID: other.ID, X: a.X, Y: a.Y, Amount: oldBEnergy})
+				s.removeAgent(other, CauseMerged, a.ID)
 				return true
 			}
 		}
@@ -840,17 +921,19 @@ func abs(x int) int {
 	return x
 }
 
-func (s *Sim) AddFoodAt(x, y int, energy float64) {
+func (s *Sim) AddFoodAt(x, y int, energy float64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if x < 0 || x >= s.W || y < 0 || y >= s.H {
-		return
+	if !s.inBounds(x, y) {
+		return ErrOutOfBounds
 	}
 	key := x*s.H + y
 	if s.foodAt(x, y) || s.agentAt(x, y) {
-		return
+		return ErrCellOccupied
 	}
 	s.foods[key] = &Food{X: x, Y: y, Energy: energy}
+	s.emit(Event{Type: EventFoodAdded, X: x, Y: y, Energy: energy})
+	return nil
 }
 
 func (s *Sim) SetRandomFood(enabled bool) {
@@ -859,11 +942,28 @@ func (s *Sim) SetRandomFood(enabled bool) {
 	s.RandomFood = enabled
 }
 
-func (s *Sim) AddAgentAt(x, y int, energy float64, sex Sex, aggression float64, speed int, strength float64, repro float64) {
+func (s *Sim) AddAgentAt(x, y int, energy float64, sex Sex, aggression float64, speed int, strength float64, repro float64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addAgentAt(x, y, energy, sex, aggression, speed, strength, repro, nil)
+}
+
+// AddAgentWithBrain plants an agent like AddAgentAt but starts it from b.
+func (s *Sim) AddAgentWithBrain(x, y int, energy float64, sex Sex, aggression float64, speed int, strength float64, repro float64, b Brain) (int, error) {
+	if err := b.Validate(); err != nil {
+		return 0, err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if x < 0 || x >= s.W || y < 0 || y >= s.H {
-		return
+	return s.addAgentAt(x, y, energy, sex, aggression, speed, strength, repro, &b)
+}
+
+func (s *Sim) addAgentAt(x, y int, energy float64, sex Sex, aggression float64, speed int, strength float64, repro float64, b *Brain) (int, error) {
+	if !s.inBounds(x, y) {
+		return 0, ErrOutOfBounds
+	}
+	if _, err := ParseSex(string(sex)); err != nil {
+		return 0, err
 	}
 	s.nextID++
 	a := &Agent{
@@ -879,28 +979,9 @@ func (s *Sim) AddAgentAt(x, y int, energy float64, sex Sex, aggression float64,
 		Repro:      clampF(repro*1.5, 0, 1),
 		Experience: map[string]int{},
 	}
-	na := 9
-	nf := 5
-	a.Weights = make([][]float64, na)
-	for i := 0; i < na; i++ {
-		a.Weights[i] = make([]float64, nf)
-		for j := 0; j < nf; j++ {
-			a.Weights[i][j] = s.rand.NormFloat64() * 0.05
-		}
-	}
-	a.LearningRate = 0.03
-	a.LastState = make([]float64, nf)
-	a.LastProbs = make([]float64, na)
-	a.CriticW = make([]float64, nf)
-	a.Gamma = 0.98
-	a.CriticLR = 2 * a.LearningRate
-	a.EntropyBeta = 0.01
-	a.AdvClip = 6.0
-	a.REstAlpha = 0.01
-	a.REps = 1e-8
-	a.PolicyDir = 4
-	a.Hunger = 0
-	s.agents[a.ID] = a
-	s.totalBirths++
-	s.lineage[a.ID] = []int{}
+	s.plant(a, 0.05)
+	if b != nil {
+		b.apply(a)
+	}
+	return a.ID, nil
 }
@@ -0,0 +1,125 @@
+package sim
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func runSeeded(t *testing.T, seed int64, ticks int) (*Sim, []MetricsSample) {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Seed = seed
+	cfg.Width, cfg.Height = 60, 60
+	cfg.InitialAgents = 30
+	s := NewSimWithConfig(cfg)
+	for i := 0; i < ticks; i++ {
+		s.Tick()
+		select {
+		case <-s.StateChan:
+		default:
+		}
+	}
+	samples, err := s.MetricsHistory(0, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, samples
+}
+
+func TestSameSeedReplays(t *testing.T) {
+	a, sa := runSeeded(t, 7, 200)
+	for run := 0; run < 2; run++ {
+		b, sb := runSeeded(t, 7, 200)
+		if !reflect.DeepEqual(sa, sb) {
+			for i := range sa {
+				if i < len(sb) && !reflect.DeepEqual(sa[i], sb[i]) {
+					t.Fatalf("run %d diverged at tick %d:\n%+v\n%+v", run, sa[i].Tick, sa[i], sb[i])
+				}
+			}
+			t.Fatalf("run %d: %d samples, want %d", run, len(sb), len(sa))
+		}
+		ta, tb := a.Telemetry(), b.Telemetry()
+		if ta.Population != tb.Population || ta.Births != tb.Births || ta.Deaths != tb.Deaths || ta.Foods != tb.Foods {
+			t.Fatalf("run %d: population/births/deaths/foods %d/%d/%d/%d, want %d/%d/%d/%d",
+				run, tb.Population, tb.Births, tb.Deaths, tb.Foods, ta.Population, ta.Births, ta.Deaths, ta.Foods)
+		}
+		if !sameAgents(a.Agents(), b.Agents()) {
+			t.Fatalf("run %d: agents differ", run)
+		}
+	}
+}
+
+func sameAgents(x, y []AgentView) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	byID := make(map[int]AgentView, len(x))
+	for _, a := range x {
+		byID[a.ID] = a
+	}
+	for _, b := range y {
+		if a, ok := byID[b.ID]; !ok || !reflect.DeepEqual(a, b) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUpdateActorCritic(t *testing.T) {
+	tests := []struct {
+		name      string
+		reward    float64
+		advClip   float64
+		wantDelta float64
+	}{
+		{"zero reward", 0, 1, 0},
+		{"positive reward", 2, 5, math.Sqrt2},
+		{"positive reward clipped", 2, 1, 1},
+		{"negative reward clipped", -2, 1, -1},
+		{"huge reward stays finite", 1e300, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testSim(t, 10, 10)
+			id, err := s.AddAgentAt(5, 5, 50, Female, 0.5, 1, 10, 0.5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a := s.agents[id]
+			for p := range a.Weights {
+				for j := range a.Weights[p] {
+					a.Weights[p][j] = 0
+				}
+			}
+			for j := range a.CriticW {
+				a.CriticW[j] = 0
+			}
+			// With alpha 0.5 and a fresh estimate, the normalised reward is
+			// sign(r)*sqrt(2), and a zero critic adds no bootstrap term.
+			a.RMean, a.RVar, a.REstAlpha, a.REps = 0, 0, 0.5, 1e-12
+			a.AdvClip, a.EntropyBeta = tt.advClip, 0
+			features, probs := s.computeFeaturesAndProbs(a)
+			const act = 2
+
+			s.updateActorCritic(a, features, probs, act, tt.reward)
+
+			for j, w := range a.CriticW {
+				if want := a.CriticLR * tt.wantDelta * features[j]; math.Abs(w-want) > 1e-9 {
+					t.Errorf("critic[%d] = %v, want %v", j, w, want)
+				}
+			}
+			for p := range a.Weights {
+				factor := -probs[p]
+				if p == act {
+					factor++
+				}
+				for j, w := range a.Weights[p] {
+					if want := a.LearningRate * tt.wantDelta * factor * features[j]; math.Abs(w-want) > 1e-9 {
+						t.Errorf("weights[%d][%d] = %v, want %v", p, j, w, want)
+					}
+				}
+			}
+		})
+	}
+}
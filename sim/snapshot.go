@@ -0,0 +1,224 @@
+package sim
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+const snapshotVersion = 1
+
+var ErrSnapshotVersion = errors.New("unsupported snapshot version")
+
+type SavedAgent struct {
+	Agent
+	Brain      Brain `json:"brain"`
+	LastAction int   `json:"last_action"`
+	Hunger     int   `json:"hunger"`
+	BirthSex   Sex   `json:"birth_sex,omitempty"`
+	BirthGen   int   `json:"birth_gen"`
+}
+
+type savedLifeRow struct {
+	LifeTableRow
+	TotalAge int `json:"total_age"`
+}
+
+type savedSeries struct {
+	Resolution int             `json:"resolution"`
+	Samples    []MetricsSample `json:"samples"`
+	Pending    MetricsSample   `json:"pending"`
+	N          int             `json:"n"`
+}
+
+type savedRates struct {
+	Births []int `json:"births"`
+	Deaths []int `json:"deaths"`
+}
+
+type Snapshot struct {
+	Version       int                `json:"version"`
+	SavedAt       time.Time          `json:"saved_at"`
+	Config        Config             `json:"config"`
+	Tick          int                `json:"tick"`
+	NextID        int                `json:"next_id"`
+	Agents        []SavedAgent       `json:"agents"`
+	Foods         []Food             `json:"foods"`
+	Lineage       map[int][]int      `json:"lineage"`
+	Histories     []*AgentHistory    `json:"histories"`
+	DeadHistory   []int              `json:"dead_history"`
+	TotalBirths   int                `json:"total_births"`
+	TotalDeaths   int                `json:"total_deaths"`
+	TotalAgeDeath int                `json:"total_age_at_death"`
+	DeathsByCause map[DeathCause]int `json:"deaths_by_cause"`
+	LifeTable     []savedLifeRow     `json:"life_table"`
+	NextEventID   uint64             `json:"next_event_id"`
+	Events        []Event            `json:"events"`
+	Metrics       []savedSeries      `json:"metrics"`
+	Rates         savedRates         `json:"rates"`
+	Trained       *Brain             `json:"trained_brain,omitempty"`
+	SeedPlanted   bool               `json:"seed_planted"`
+	Demos         []Demonstration    `json:"demonstrations"`
+}
+
+func (s *Sim) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := Snapshot{
+		Version:       snapshotVersion,
+		SavedAt:       time.Now().UTC(),
+		Config:        s.config(),
+		Tick:          s.ticksElapsed,
+		NextID:        s.nextID,
+		Lineage:       make(map[int][]int, len(s.lineage)),
+		DeadHistory:   append([]int(nil), s.deadHistory...),
+		TotalBirths:   s.totalBirths,
+		TotalDeaths:   s.totalDeaths,
+		TotalAgeDeath: s.totalAgeAtDeath,
+		DeathsByCause: make(map[DeathCause]int, len(s.demo.byCause)),
+		NextEventID:   s.events.nextID,
+		Events:        s.eventsSince(0, 0),
+		Rates:         savedRates{Births: append([]int(nil), s.demo.birthsWin...), Deaths: append([]int(nil), s.demo.deathsWin...)},
+		SeedPlanted:   s.seedPlanted,
+		Demos:         append([]Demonstration(nil), s.demos...),
+	}
+	for _, sr := range s.series {
+		snap.Metrics = append(snap.Metrics, savedSeries{Resolution: sr.resolution, Samples: append([]MetricsSample(nil), sr.samples...), Pending: sr.acc, N: sr.n})
+	}
+	if s.trained != nil {
+		b := *s.trained
+		snap.Trained = &b
+	}
+	ids := make([]int, 0, len(s.agents))
+	for id := range s.agents {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		a := s.agents[id]
+		sa := SavedAgent{Agent: *a, Brain: brainOf(a), LastAction: a.LastAction, Hunger: a.Hunger, BirthSex: a.life.sex, BirthGen: a.life.gen}
+		sa.Experience = viewOf(a).Experience
+		sa.Parents = append([]int(nil), a.Parents...)
+		snap.Agents = append(snap.Agents, sa)
+	}
+	for _, f := range s.foods {
+		snap.Foods = append(snap.Foods, *f)
+	}
+	for id, parents := range s.lineage {
+		snap.Lineage[id] = append([]int(nil), parents...)
+	}
+	for _, h := range s.histories {
+		snap.Histories = append(snap.Histories, h.clone())
+	}
+	for c, n := range s.demo.byCause {
+		snap.DeathsByCause[c] = n
+	}
+	for _, r := range s.demo.lifeTable {
+		snap.LifeTable = append(snap.LifeTable, savedLifeRow{LifeTableRow: *r, TotalAge: r.TotalAge})
+	}
+	sort.Slice(snap.Foods, func(i, j int) bool {
+		return snap.Foods[i].X*s.H+snap.Foods[i].Y < snap.Foods[j].X*s.H+snap.Foods[j].Y
+	})
+	sort.Slice(snap.Histories, func(i, j int) bool { return snap.Histories[i].ID < snap.Histories[j].ID })
+	sort.Slice(snap.LifeTable, func(i, j int) bool {
+		a, b := snap.LifeTable[i], snap.LifeTable[j]
+		if a.Generation != b.Generation {
+			return a.Generation < b.Generation
+		}
+		return a.Sex < b.Sex
+	})
+	return snap
+}
+
+func (s *Sim) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s.Snapshot())
+}
+
+func LoadSim(r io.Reader) (*Sim, error) {
+	var snap Snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, err
+	}
+	if snap.Version != snapshotVersion {
+		return nil, ErrSnapshotVersion
+	}
+	if err := snap.Config.Validate(); err != nil {
+		return nil, err
+	}
+	for i := range snap.Agents {
+		snap.Agents[i].Brain = snap.Agents[i].Brain.repaired()
+		if err := snap.Agents[i].Brain.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if snap.Trained != nil {
+		if err := snap.Trained.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	cfg := snap.Config
+	initial := cfg.InitialAgents
+	cfg.InitialAgents = 0
+	s := NewSimWithConfig(cfg)
+	s.initialAgents = initial
+	s.ticksElapsed = snap.Tick
+	s.nextID = snap.NextID
+	for i := range snap.Agents {
+		sa := snap.Agents[i]
+		a := sa.Agent
+		sa.Brain.apply(&a)
+		a.LastAction = sa.LastAction
+		a.Hunger = sa.Hunger
+		a.life = lifeKey{sa.BirthSex, sa.BirthGen}
+		if sa.BirthSex == "" {
+			a.life = lifeKey{a.Sex, a.Generation}
+		}
+		if a.Experience == nil {
+			a.Experience = map[string]int{}
+		}
+		s.agents[a.ID] = &a
+	}
+	for i := range snap.Foods {
+		f := snap.Foods[i]
+		s.foods[f.X*s.H+f.Y] = &f
+	}
+	if snap.Lineage != nil {
+		s.lineage = snap.Lineage
+	}
+	for _, h := range snap.Histories {
+		s.histories[h.ID] = h
+	}
+	s.deadHistory = snap.DeadHistory
+	s.totalBirths = snap.TotalBirths
+	s.totalDeaths = snap.TotalDeaths
+	s.totalAgeAtDeath = snap.TotalAgeDeath
+	for c, n := range snap.DeathsByCause {
+		s.demo.byCause[c] = n
+	}
+	for _, r := range snap.LifeTable {
+		row := r.LifeTableRow
+		row.TotalAge = r.TotalAge
+		s.demo.lifeTable[lifeKey{row.Sex, row.Generation}] = &row
+	}
+	s.restoreEvents(snap.NextEventID, snap.Events)
+	for _, saved := range snap.Metrics {
+		for _, sr := range s.series {
+			if sr.resolution == saved.Resolution {
+				sr.samples, sr.acc, sr.n = saved.Samples, saved.Pending, saved.N
+			}
+		}
+	}
+	s.demo.birthsWin, s.demo.deathsWin = snap.Rates.Births, snap.Rates.Deaths
+	for _, n := range s.demo.birthsWin {
+		s.demo.birthsSum += n
+	}
+	for _, n := range s.demo.deathsWin {
+		s.demo.deathsSum += n
+	}
+	s.trained = snap.Trained
+	s.seedPlanted = snap.SeedPlanted && s.trained != nil
+	s.demos = snap.Demos
+	return s, nil
+}
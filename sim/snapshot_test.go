@@ -0,0 +1,81 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func snapshotJSON(t *testing.T, s *Sim) []byte {
+	t.Helper()
+	snap := s.Snapshot()
+	snap.SavedAt = time.Time{}
+	raw, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed, cfg.Width, cfg.Height, cfg.InitialAgents = 11, 40, 40, 20
+	s := NewSimWithConfig(cfg)
+	for i := 0; i < 120; i++ {
+		s.Tick()
+		<-s.StateChan
+	}
+	b, rep, err := TrainBrain(s.NewBrain(), s.HeuristicDemonstrations(50), TrainOptions{Epochs: 5, LR: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetTrainedBrain(b, rep)
+	if err := s.SeedPlanted(true); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.recordDemo(Demonstration{Tick: s.ticksElapsed, AgentID: 1, Obs: []float64{1, 0, 0, 1, 0}, Action: 4, Reward: 0.5})
+	s.mu.Unlock()
+
+	var buf bytes.Buffer
+	if err := s.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSim(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := snapshotJSON(t, s), snapshotJSON(t, loaded); !bytes.Equal(want, got) {
+		t.Fatalf("snapshot changed across save and load:\nwant %.400s\ngot  %.400s", want, got)
+	}
+
+	tests := []struct {
+		name string
+		get  func(*Sim) interface{}
+	}{
+		{"last event id", func(s *Sim) interface{} { return s.LastEventID() }},
+		{"events since 0", func(s *Sim) interface{} { return s.EventsSince(0, 0) }},
+		{"events since cursor", func(s *Sim) interface{} { return s.EventsSince(s.LastEventID()-3, 0) }},
+		{"metrics history", func(s *Sim) interface{} { h, _ := s.MetricsHistory(0, 0, 10); return h }},
+		{"demographics", func(s *Sim) interface{} { return s.demographicMetrics(s.sortedAgents()) }},
+		{"trained brain", func(s *Sim) interface{} { b, ok := s.TrainedBrain(); return []interface{}{b, ok} }},
+		{"demonstrations", func(s *Sim) interface{} { return s.Demonstrations(0, 0) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if want, got := tt.get(s), tt.get(loaded); !reflect.DeepEqual(want, got) {
+				t.Fatalf("got %v\nwant %v", got, want)
+			}
+		})
+	}
+
+	last := s.LastEventID()
+	loaded.mu.Lock()
+	loaded.emit(Event{Type: EventFoodAdded})
+	loaded.mu.Unlock()
+	if got := loaded.EventsSince(last, 0); len(got) != 1 || got[0].ID != last+1 {
+		t.Fatalf("first event after load = %+v, want id %d", got, last+1)
+	}
+}
@@ -0,0 +1,83 @@
+package sim
+
+import "time"
+
+var TickDurationBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// Telemetry is a point-in-time copy of a world's counters. The *Total and
+// tick histogram fields only ever grow for the life of the Sim, across
+// resets; Ticks, Births and Deaths follow the current run and are rewound by
+// reset and snapshot load.
+type Telemetry struct {
+	Ticks           int
+	TicksTotal      uint64
+	BirthsTotal     uint64
+	DeathsTotal     uint64
+	TickBuckets     []uint64
+	TickSum         float64
+	DroppedStates   uint64
+	SubscriberDrops uint64
+	EventsByType    map[string]uint64
+	Population      int
+	Foods           int
+	Births          int
+	Deaths          int
+	Learning        LearningStats
+	Quarantined     uint64
+}
+
+type telemetry struct {
+	ticks           uint64
+	births          uint64
+	deaths          uint64
+	quarantined     uint64
+	tickBuckets     []uint64
+	tickSum         float64
+	droppedStates   uint64
+	subscriberDrops uint64
+	eventsByType    map[string]uint64
+}
+
+func newTelemetry() telemetry {
+	return telemetry{
+		tickBuckets:  make([]uint64, len(TickDurationBuckets)),
+		eventsByType: make(map[string]uint64),
+	}
+}
+
+func (s *Sim) observeTick(start time.Time) {
+	d := time.Since(start).Seconds()
+	s.tel.ticks++
+	s.tel.tickSum += d
+	for i, b := range TickDurationBuckets {
+		if d <= b {
+			s.tel.tickBuckets[i]++
+		}
+	}
+}
+
+func (s *Sim) Telemetry() Telemetry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := Telemetry{
+		Ticks:           s.ticksElapsed,
+		TicksTotal:      s.tel.ticks,
+		BirthsTotal:     s.tel.births,
+		DeathsTotal:     s.tel.deaths,
+		TickBuckets:     append([]uint64{}, s.tel.tickBuckets...),
+		TickSum:         s.tel.tickSum,
+		DroppedStates:   s.tel.droppedStates,
+		SubscriberDrops: s.tel.subscriberDrops,
+		EventsByType:    make(map[string]uint64, len(s.tel.eventsByType)),
+		Population:      len(s.agents),
+		Foods:           len(s.foods),
+		Births:          s.totalBirths,
+		Deaths:          s.totalDeaths,
+		Learning:        s.learn.last,
+		Quarantined:     s.tel.quarantined,
+	}
+	for k, v := range s.tel.eventsByType {
+		t.EventsByType[k] = v
+	}
+	return t
+}
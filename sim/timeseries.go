@@ -0,0 +1,164 @@
+package sim
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const seriesCapacity = 2000
+
+var Resolutions = []int{1, 10, 100}
+
+type MetricsSample struct {
+	Tick          int     `json:"tick"`
+	Population    float64 `json:"population"`
+	AvgEnergy     float64 `json:"avg_energy"`
+	AvgAggression float64 `json:"avg_aggression"`
+	AvgStrength   float64 `json:"avg_strength"`
+	AvgSpeed      float64 `json:"avg_speed"`
+	AvgRepro      float64 `json:"avg_repro"`
+	Foods         float64 `json:"foods"`
+	Births        int     `json:"births"`
+	Deaths        int     `json:"deaths"`
+	PolicyEntropy float64 `json:"policy_entropy"`
+	AbsTD         float64 `json:"abs_td"`
+	AdvClipFrac   float64 `json:"adv_clip_frac"`
+	WeightNorm    float64 `json:"weight_norm"`
+	Quarantined   int     `json:"quarantined"`
+}
+
+type series struct {
+	resolution int
+	samples    []MetricsSample
+	acc        MetricsSample
+	n          int
+}
+
+func newSeries() []*series {
+	out := make([]*series, 0, len(Resolutions))
+	for _, r := range Resolutions {
+		out = append(out, &series{resolution: r, samples: make([]MetricsSample, 0)})
+	}
+	return out
+}
+
+func (sr *series) add(m MetricsSample) {
+	sr.acc.Population += m.Population
+	sr.acc.AvgEnergy += m.AvgEnergy
+	sr.acc.AvgAggression += m.AvgAggression
+	sr.acc.AvgStrength += m.AvgStrength
+	sr.acc.AvgSpeed += m.AvgSpeed
+	sr.acc.AvgRepro += m.AvgRepro
+	sr.acc.Foods += m.Foods
+	sr.acc.Births += m.Births
+	sr.acc.Deaths += m.Deaths
+	sr.acc.PolicyEntropy += m.PolicyEntropy
+	sr.acc.AbsTD += m.AbsTD
+	sr.acc.AdvClipFrac += m.AdvClipFrac
+	sr.acc.WeightNorm += m.WeightNorm
+	sr.acc.Quarantined += m.Quarantined
+	sr.n++
+	if sr.n < sr.resolution {
+		return
+	}
+	n := float64(sr.n)
+	out := MetricsSample{
+		Tick:          m.Tick,
+		Population:    sr.acc.Population / n,
+		AvgEnergy:     sr.acc.AvgEnergy / n,
+		AvgAggression: sr.acc.AvgAggression / n,
+		AvgStrength:   sr.acc.AvgStrength / n,
+		AvgSpeed:      sr.acc.AvgSpeed / n,
+		AvgRepro:      sr.acc.AvgRepro / n,
+		Foods:         sr.acc.Foods / n,
+		Births:        sr.acc.Births,
+		Deaths:        sr.acc.Deaths,
+		PolicyEntropy: sr.acc.PolicyEntropy / n,
+		AbsTD:         sr.acc.AbsTD / n,
+		AdvClipFrac:   sr.acc.AdvClipFrac / n,
+		WeightNorm:    sr.acc.WeightNorm / n,
+		Quarantined:   sr.acc.Quarantined,
+	}
+	if len(sr.samples) >= seriesCapacity {
+		sr.samples = sr.samples[1:]
+	}
+	sr.samples = append(sr.samples, out)
+	sr.acc = MetricsSample{}
+	sr.n = 0
+}
+
+func (s *Sim) recordSample(list []*Agent, learn LearningStats) {
+	m := MetricsSample{
+		Tick:          s.ticksElapsed,
+		Population:    float64(len(list)),
+		Foods:         float64(len(s.foods)),
+		Births:        s.demo.tickBirths,
+		Deaths:        s.demo.tickDeaths,
+		PolicyEntropy: learn.PolicyEntropy,
+		AbsTD:         learn.AbsTD,
+		AdvClipFrac:   learn.AdvClipFrac,
+		WeightNorm:    learn.WeightNorm.Mean,
+		Quarantined:   learn.Quarantined,
+	}
+	if len(list) > 0 {
+		for _, a := range list {
+			m.AvgEnergy += a.Energy
+			m.AvgAggression += a.Aggression
+			m.AvgStrength += a.Strength
+			m.AvgSpeed += float64(a.Speed)
+			m.AvgRepro += a.Repro
+		}
+		n := float64(len(list))
+		m.AvgEnergy /= n
+		m.AvgAggression /= n
+		m.AvgStrength /= n
+		m.AvgSpeed /= n
+		m.AvgRepro /= n
+	}
+	for _, sr := range s.series {
+		sr.add(m)
+	}
+}
+
+func (s *Sim) MetricsHistory(from, to, resolution int) ([]MetricsSample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sr := range s.series {
+		if sr.resolution != resolution {
+			continue
+		}
+		out := make([]MetricsSample, 0)
+		for _, m := range sr.samples {
+			if m.Tick < from || (to > 0 && m.Tick > to) {
+				continue
+			}
+			out = append(out, m)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported resolution %d (want one of %v)", resolution, Resolutions)
+}
+
+func WriteMetricsCSV(w io.Writer, samples []MetricsSample) error {
+	cw := csv.NewWriter(w)
+	header := []string{"tick", "population", "avg_energy", "avg_aggression", "avg_strength", "avg_speed", "avg_repro", "foods", "births", "deaths", "policy_entropy", "abs_td", "adv_clip_frac", "weight_norm", "quarantined"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+	for _, m := range samples {
+		row := []string{
+			strconv.Itoa(m.Tick), f(m.Population), f(m.AvgEnergy), f(m.AvgAggression),
+			f(m.AvgStrength), f(m.AvgSpeed), f(m.AvgRepro), f(m.Foods),
+			strconv.Itoa(m.Births), strconv.Itoa(m.Deaths),
+			f(m.PolicyEntropy), f(m.AbsTD), f(m.AdvClipFrac), f(m.WeightNorm), strconv.Itoa(m.Quarantined),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
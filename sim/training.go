@@ -0,0 +1,146 @@
+package sim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+const (
+	EventTrainingFailed EventType = "brain_training_failed"
+
+	// MaxTrainWork bounds epochs × samples of one training job.
+	MaxTrainWork = 10000000
+
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+var ErrTrainingBusy = errors.New("a training job is already running")
+
+// TrainJob is a background behavioural-cloning run started by StartTraining.
+type TrainJob struct {
+	ID     int          `json:"id"`
+	State  string       `json:"state"`
+	Source string       `json:"source"`
+	Report *TrainReport `json:"report,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+type training struct {
+	nextID int
+	job    *TrainJob
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func checkTrainWork(epochs, samples int) error {
+	if epochs*samples > MaxTrainWork {
+		return fmt.Errorf("%w: epochs × samples must be at most %d, got %d × %d", ErrBadTraining, MaxTrainWork, epochs, samples)
+	}
+	return nil
+}
+
+// StartTraining fits a fresh brain to the newest MaxTrainSamples demos in
+// the background and returns at once. When the job ends the result becomes
+// the trained brain and a brain_trained event is emitted, or
+// brain_training_failed if it failed or was cancelled. Only one job runs at a
+// time; Run cancels it on exit.
+func (s *Sim) StartTraining(demos []Demonstration, opts TrainOptions, source string) (TrainJob, error) {
+	if len(demos) == 0 {
+		return TrainJob{}, ErrNoSamples
+	}
+	if len(demos) > MaxTrainSamples {
+		demos = demos[len(demos)-MaxTrainSamples:]
+	}
+	return s.startTraining(opts, source, len(demos), func(context.Context) ([]Demonstration, error) {
+		return demos, nil
+	})
+}
+
+// StartHeuristicTraining is StartTraining on n heuristic demonstrations. The
+// job generates them itself, taking the world lock only in short batches, so
+// ticks and commands go on meanwhile.
+func (s *Sim) StartHeuristicTraining(n int, opts TrainOptions) (TrainJob, error) {
+	if n < 1 || n > MaxTrainSamples {
+		return TrainJob{}, fmt.Errorf("%w: samples must be 1-%d, got %d", ErrBadTraining, MaxTrainSamples, n)
+	}
+	s.mu.Lock()
+	rng := rand.New(rand.NewSource(s.rand.Int63()))
+	strength := s.meanStrength()
+	s.mu.Unlock()
+	return s.startTraining(opts, "heuristic", n, func(ctx context.Context) ([]Demonstration, error) {
+		return s.heuristicDemonstrations(ctx, rng, strength, n)
+	})
+}
+
+func (s *Sim) startTraining(opts TrainOptions, source string, samples int, gather func(context.Context) ([]Demonstration, error)) (TrainJob, error) {
+	if err := opts.validate(); err != nil {
+		return TrainJob{}, err
+	}
+	if err := checkTrainWork(opts.Epochs, samples); err != nil {
+		return TrainJob{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := &s.train
+	if t.job != nil && t.job.State == JobRunning {
+		return TrainJob{}, ErrTrainingBusy
+	}
+	var scratch Agent
+	s.initBrain(&scratch, 0.05)
+	base := brainOf(&scratch)
+
+	t.nextID++
+	job := &TrainJob{ID: t.nextID, State: JobRunning, Source: source}
+	t.job = job
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		defer cancel()
+		demos, err := gather(ctx)
+		var b Brain
+		var rep TrainReport
+		if err == nil {
+			b, rep, err = trainBrain(ctx, base, demos, opts)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err != nil {
+			job.State, job.Error = JobFailed, err.Error()
+			s.emit(Event{Type: EventTrainingFailed, Job: job.ID})
+			return
+		}
+		rep.Source = source
+		job.State, job.Report = JobDone, &rep
+		s.trained = &b
+		s.emit(Event{Type: EventBrainTrained, Count: rep.Samples, Job: job.ID})
+	}()
+	return *job, nil
+}
+
+// TrainingJob returns the latest training job.
+func (s *Sim) TrainingJob() (TrainJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.train.job == nil {
+		return TrainJob{}, false
+	}
+	return *s.train.job, true
+}
+
+// stopTraining cancels a running job and waits for it to finish.
+func (s *Sim) stopTraining() {
+	s.mu.Lock()
+	cancel := s.train.cancel
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	s.train.wg.Wait()
+}
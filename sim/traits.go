@@ -0,0 +1,148 @@
+package sim
+
+import (
+	"math"
+	"sort"
+)
+
+const traitBins = 10
+
+var traitQuantiles = []struct {
+	name string
+	q    float64
+}{{"p10", 0.1}, {"p25", 0.25}, {"p50", 0.5}, {"p75", 0.75}, {"p90", 0.9}}
+
+var traitFuncs = []struct {
+	name string
+	get  func(a *Agent) float64
+}{
+	{"agg", func(a *Agent) float64 { return a.Aggression }},
+	{"strength", func(a *Agent) float64 { return a.Strength }},
+	{"spd", func(a *Agent) float64 { return float64(a.Speed) }},
+	{"repro", func(a *Agent) float64 { return a.Repro }},
+	{"lr", func(a *Agent) float64 { return a.LearningRate }},
+	{"energy", func(a *Agent) float64 { return a.Energy }},
+}
+
+type HistBin struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
+
+type TraitSummary struct {
+	Count     int                `json:"count"`
+	Min       float64            `json:"min"`
+	Max       float64            `json:"max"`
+	Mean      float64            `json:"mean"`
+	Quantiles map[string]float64 `json:"quantiles"`
+	Histogram []HistBin          `json:"histogram"`
+}
+
+type TraitStats struct {
+	Tick      int                             `json:"tick"`
+	All       map[string]TraitSummary         `json:"all"`
+	BySex     map[Sex]map[string]TraitSummary `json:"by_sex"`
+	BySpecies map[int]map[string]TraitSummary `json:"by_species"`
+}
+
+func summarize(values []float64) TraitSummary {
+	ts := TraitSummary{Count: len(values), Quantiles: map[string]float64{}, Histogram: []HistBin{}}
+	if len(values) == 0 {
+		return ts
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	ts.Min = sorted[0]
+	ts.Max = sorted[len(sorted)-1]
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	ts.Mean = sum / float64(len(sorted))
+	for _, q := range traitQuantiles {
+		ts.Quantiles[q.name] = quantile(sorted, q.q)
+	}
+	width := (ts.Max - ts.Min) / traitBins
+	if width <= 0 {
+		ts.Histogram = append(ts.Histogram, HistBin{From: ts.Min, To: ts.Max, Count: len(sorted)})
+		return ts
+	}
+	ts.Histogram = make([]HistBin, traitBins)
+	for i := range ts.Histogram {
+		ts.Histogram[i].From = ts.Min + float64(i)*width
+		ts.Histogram[i].To = ts.Min + float64(i+1)*width
+	}
+	for _, v := range sorted {
+		b := int(math.Floor((v - ts.Min) / width))
+		if b >= traitBins {
+			b = traitBins - 1
+		}
+		ts.Histogram[b].Count++
+	}
+	return ts
+}
+
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	frac := pos - float64(lo)
+	return sorted[lo]*(1-frac) + sorted[hi]*frac
+}
+
+func summarizeTraits(list []*Agent) map[string]TraitSummary {
+	out := make(map[string]TraitSummary, len(traitFuncs))
+	values := make([]float64, len(list))
+	for _, tf := range traitFuncs {
+		for i, a := range list {
+			values[i] = tf.get(a)
+		}
+		out[tf.name] = summarize(values)
+	}
+	return out
+}
+
+func (s *Sim) traitStats(list []*Agent) TraitStats {
+	bySex := map[Sex][]*Agent{}
+	bySpecies := map[int][]*Agent{}
+	for _, a := range list {
+		bySex[a.Sex] = append(bySex[a.Sex], a)
+		bySpecies[a.Species] = append(bySpecies[a.Species], a)
+	}
+	st := TraitStats{
+		Tick:      s.ticksElapsed,
+		All:       summarizeTraits(list),
+		BySex:     make(map[Sex]map[string]TraitSummary, len(bySex)),
+		BySpecies: make(map[int]map[string]TraitSummary, len(bySpecies)),
+	}
+	for k, v := range bySex {
+		st.BySex[k] = summarizeTraits(v)
+	}
+	for k, v := range bySpecies {
+		st.BySpecies[k] = summarizeTraits(v)
+	}
+	return st
+}
+
+func (s *Sim) TraitStats() TraitStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*Agent, 0, len(s.agents))
+	for _, a := range s.agents {
+		list = append(list, a)
+	}
+	return s.traitStats(list)
+}
+
+func (s *Sim) SetTraitStatsEvery(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	s.TraitStatsEvery = n
+}
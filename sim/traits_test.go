@@ -0,0 +1,120 @@
+package sim
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestQuantile(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted []float64
+		q      float64
+		want   float64
+	}{
+		{"single value", []float64{7}, 0.9, 7},
+		{"minimum", []float64{1, 2, 3, 4}, 0, 1},
+		{"maximum", []float64{1, 2, 3, 4}, 1, 4},
+		{"median between two", []float64{1, 2, 3, 4}, 0.5, 2.5},
+		{"median on a value", []float64{1, 2, 3}, 0.5, 2},
+		{"quarter interpolated", []float64{1, 2, 3, 4}, 0.25, 1.75},
+		{"p10 of two", []float64{10, 20}, 0.1, 11},
+		{"p90 of two", []float64{10, 20}, 0.9, 19},
+	}
+	for _, tt := range tests {
+		if got := quantile(tt.sorted, tt.q); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: quantile(%v, %v) = %v, want %v", tt.name, tt.sorted, tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tenBins := func(counts ...int) []HistBin {
+		out := make([]HistBin, traitBins)
+		for i := range out {
+			out[i] = HistBin{From: float64(i), To: float64(i + 1), Count: counts[i]}
+		}
+		return out
+	}
+	tests := []struct {
+		name   string
+		values []float64
+		want   TraitSummary
+	}{
+		{"empty", nil, TraitSummary{Quantiles: map[string]float64{}, Histogram: []HistBin{}}},
+		{"single value", []float64{5, 5, 5}, TraitSummary{
+			Count: 3, Min: 5, Max: 5, Mean: 5,
+			Quantiles: map[string]float64{"p10": 5, "p25": 5, "p50": 5, "p75": 5, "p90": 5},
+			Histogram: []HistBin{{From: 5, To: 5, Count: 3}},
+		}},
+		{"max lands in the last bin", []float64{10, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, TraitSummary{
+			Count: 11, Min: 0, Max: 10, Mean: 5,
+			Quantiles: map[string]float64{"p10": 1, "p25": 2.5, "p50": 5, "p75": 7.5, "p90": 9},
+			Histogram: tenBins(1, 1, 1, 1, 1, 1, 1, 1, 1, 2),
+		}},
+		{"skewed", []float64{0, 0, 0, 10}, TraitSummary{
+			Count: 4, Min: 0, Max: 10, Mean: 2.5,
+			Quantiles: map[string]float64{"p10": 0, "p25": 0, "p50": 0, "p75": 2.5, "p90": 7},
+			Histogram: tenBins(3, 0, 0, 0, 0, 0, 0, 0, 0, 1),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summarize(tt.values)
+			for k, v := range got.Quantiles {
+				got.Quantiles[k] = math.Round(v*1e9) / 1e9
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("summarize(%v) =\n%+v\nwant\n%+v", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTraitStatsBreakdown(t *testing.T) {
+	s := testSim(t, 10, 10)
+	agents := []struct {
+		sex      Sex
+		strength float64
+		species  int
+	}{
+		{Female, 2, 1},
+		{Female, 4, 1},
+		{Male, 6, 1},
+		{Male, 12, 2},
+	}
+	for i, a := range agents {
+		id, err := s.AddAgentAt(i, 0, 50, a.sex, 0.5, 1, a.strength, 0.5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.agents[id].Species = a.species
+	}
+	st := s.TraitStats()
+
+	tests := []struct {
+		name  string
+		group map[string]TraitSummary
+		count int
+		mean  float64
+	}{
+		{"all", st.All, 4, 6},
+		{"female", st.BySex[Female], 2, 3},
+		{"male", st.BySex[Male], 2, 9},
+		{"species 1", st.BySpecies[1], 3, 4},
+		{"species 2", st.BySpecies[2], 1, 12},
+	}
+	for _, tt := range tests {
+		got := tt.group["strength"]
+		if got.Count != tt.count || got.Mean != tt.mean {
+			t.Errorf("%s: strength count %d mean %v, want %d and %v", tt.name, got.Count, got.Mean, tt.count, tt.mean)
+		}
+		if len(tt.group) != len(traitFuncs) {
+			t.Errorf("%s: %d traits, want %d", tt.name, len(tt.group), len(traitFuncs))
+		}
+	}
+	if len(st.BySex) != 2 || len(st.BySpecies) != 2 {
+		t.Fatalf("groups: %d sexes, %d species", len(st.BySex), len(st.BySpecies))
+	}
+}
@@ -0,0 +1,114 @@
+package sim
+
+type AgentView struct {
+	ID         int            `json:"id"`
+	X          int            `json:"x"`
+	Y          int            `json:"y"`
+	Energy     float64        `json:"energy"`
+	Age        int            `json:"age"`
+	Sex        Sex            `json:"sex"`
+	Speed      int            `json:"spd"`
+	Aggression float64        `json:"agg"`
+	Repro      float64        `json:"repro"`
+	Experience map[string]int `json:"exp"`
+	Parents    []int          `json:"parents"`
+	Strength   float64        `json:"strength"`
+	PolicyDir  int            `json:"policy_dir"`
+	Generation int            `json:"gen"`
+	Species    int            `json:"species"`
+}
+
+func viewOf(a *Agent) AgentView {
+	exp := make(map[string]int, len(a.Experience))
+	for k, v := range a.Experience {
+		exp[k] = v
+	}
+	return AgentView{
+		ID:         a.ID,
+		X:          a.X,
+		Y:          a.Y,
+		Energy:     a.Energy,
+		Age:        a.Age,
+		Sex:        a.Sex,
+		Speed:      a.Speed,
+		Aggression: a.Aggression,
+		Repro:      a.Repro,
+		Experience: exp,
+		Parents:    append([]int(nil), a.Parents...),
+		Strength:   a.Strength,
+		PolicyDir:  a.PolicyDir,
+		Generation: a.Generation,
+		Species:    a.Species,
+	}
+}
+
+type Rect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+func (r Rect) Contains(x, y int) bool {
+	return x >= r.X && x < r.X+r.W && y >= r.Y && y < r.Y+r.H
+}
+
+func (r Rect) Grow(margin int) Rect {
+	return Rect{X: r.X - margin, Y: r.Y - margin, W: r.W + 2*margin, H: r.H + 2*margin}
+}
+
+type DensityTile struct {
+	X      int     `json:"x"`
+	Y      int     `json:"y"`
+	Size   int     `json:"size"`
+	Male   int     `json:"m"`
+	Female int     `json:"f"`
+	Foods  int     `json:"foods"`
+	Energy float64 `json:"energy"`
+}
+
+func DensityTiles(agents []AgentView, foods []Food, r Rect, size int) []DensityTile {
+	if size < 1 {
+		size = 1
+	}
+	tiles := make(map[[2]int]*DensityTile)
+	tile := func(x, y int) *DensityTile {
+		k := [2]int{floorDiv(x, size), floorDiv(y, size)}
+		t, ok := tiles[k]
+		if !ok {
+			t = &DensityTile{X: k[0] * size, Y: k[1] * size, Size: size}
+			tiles[k] = t
+		}
+		return t
+	}
+	for _, a := range agents {
+		if !r.Contains(a.X, a.Y) {
+			continue
+		}
+		t := tile(a.X, a.Y)
+		if a.Sex == Female {
+			t.Female++
+		} else {
+			t.Male++
+		}
+		t.Energy += a.Energy
+	}
+	for _, f := range foods {
+		if r.Contains(f.X, f.Y) {
+			tile(f.X, f.Y).Foods++
+		}
+	}
+	out := make([]DensityTile, 0, len(tiles))
+	for _, t := range tiles {
+		out = append(out, *t)
+	}
+	return out
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,54 @@
+package sim
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestDensityTiles(t *testing.T) {
+	agents := []AgentView{
+		{X: 0, Y: 0, Sex: Female, Energy: 10},
+		{X: 3, Y: 3, Sex: Male, Energy: 5},
+		{X: 4, Y: 0, Sex: Male, Energy: 1},
+		{X: -1, Y: -1, Sex: Female, Energy: 2},
+		{X: 50, Y: 50, Sex: Male, Energy: 100},
+	}
+	foods := []Food{{X: 1, Y: 1}, {X: 7, Y: 7}, {X: -4, Y: -3}, {X: 60, Y: 60}}
+
+	tests := []struct {
+		name string
+		r    Rect
+		size int
+		want []DensityTile
+	}{
+		{"4-cell tiles", Rect{X: 0, Y: 0, W: 10, H: 10}, 4, []DensityTile{
+			{X: 0, Y: 0, Size: 4, Male: 1, Female: 1, Foods: 1, Energy: 15},
+			{X: 4, Y: 0, Size: 4, Male: 1, Energy: 1},
+			{X: 4, Y: 4, Size: 4, Foods: 1},
+		}},
+		{"negative cells floor into their own tile", Rect{X: -5, Y: -5, W: 6, H: 6}, 4, []DensityTile{
+			{X: -4, Y: -4, Size: 4, Female: 1, Foods: 1, Energy: 2},
+			{X: 0, Y: 0, Size: 4, Female: 1, Energy: 10},
+		}},
+		{"size below one is one", Rect{X: 0, Y: 0, W: 2, H: 2}, 0, []DensityTile{
+			{X: 0, Y: 0, Size: 1, Female: 1, Energy: 10},
+			{X: 1, Y: 1, Size: 1, Foods: 1},
+		}},
+		{"empty rect", Rect{X: 20, Y: 20, W: 5, H: 5}, 4, []DensityTile{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DensityTiles(agents, foods, tt.r, tt.size)
+			sort.Slice(got, func(i, j int) bool {
+				if got[i].Y != got[j].Y {
+					return got[i].Y < got[j].Y
+				}
+				return got[i].X < got[j].X
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("DensityTiles = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,4 +1,7 @@
-const ws = new WebSocket((location.protocol === 'https:' ? 'wss://' : 'ws://') + location.host + '/ws');
+const lang = new URLSearchParams(location.search).get('lang') || (navigator.language || 'en').slice(0, 2);
+const token = new URLSearchParams(location.search).get('token');
+const world = new URLSearchParams(location.search).get('world');
+const ws = new WebSocket((location.protocol === 'https:' ? 'wss://' : 'ws://') + location.host + '/ws?lang=' + encodeURIComponent(lang) + (token ? '&token=' + encodeURIComponent(token) : '') + (world ? '&world=' + encodeURIComponent(world) : ''));
 let W = 60, H = 40;
 const canvas = document.getElementById('field');
 const ctx = canvas.getContext('2d');
@@ -10,6 +13,10 @@ let selectedAgent = null;
 let randomFoodEnabled = true;
 let addFoodMode = false;
 let plantAgentMode = false;
+let events = [];
+let view = { x: 0, y: 0, zoom: 1 };
+let viewportTimer = null;
+const maxEvents = 1000;
 
 document.getElementById('toggleFood').onclick = () => {
   randomFoodEnabled = !randomFoodEnabled;
@@ -33,35 +40,77 @@ document.getElementById('plantAgentBtn').onclick = () => {
 
 ws.onmessage = (ev) => {
   const msg = JSON.parse(ev.data);
-  if (msg.type === 'config') { W = msg.w; H = msg.h; resize(); return }
+  if (msg.type === 'config') { W = msg.w; H = msg.h; view = { x: 0, y: 0, zoom: 1 }; resize(); sendViewport(); return }
   if (msg.type === 'state') renderState(msg);
+  if (msg.type === 'reply') handleReply(msg);
 }
 
 function resize() {
   canvas.width = 900; canvas.height = 600;
 }
 
+function cellSize() {
+  return { cellW: canvas.width / W * view.zoom, cellH: canvas.height / H * view.zoom };
+}
+
+function visibleRect() {
+  const { cellW, cellH } = cellSize();
+  return { x: Math.floor(view.x), y: Math.floor(view.y), w: Math.ceil(canvas.width / cellW) + 1, h: Math.ceil(canvas.height / cellH) + 1 };
+}
+
+function sendViewport() {
+  if (viewportTimer) return;
+  viewportTimer = setTimeout(() => {
+    viewportTimer = null;
+    if (ws.readyState !== WebSocket.OPEN) return;
+    const r = visibleRect();
+    const { cellW, cellH } = cellSize();
+    ws.send(JSON.stringify({ type: 'viewport', x: r.x, y: r.y, w: r.w, h: r.h, zoom: Math.min(cellW, cellH) }));
+  }, 100);
+}
+
+function clampView() {
+  const r = visibleRect();
+  view.x = Math.max(0, Math.min(view.x, W - r.w + 1));
+  view.y = Math.max(0, Math.min(view.y, H - r.h + 1));
+}
+
 function renderState(state) {
   if (paused) return;
-  const cellW = canvas.width / W;
-  const cellH = canvas.height / H;
+  const { cellW, cellH } = cellSize();
+  const ox = -view.x * cellW, oy = -view.y * cellH;
   ctx.fillStyle = '#071216'; ctx.fillRect(0, 0, canvas.width, canvas.height);
-  ctx.strokeStyle = 'rgba(255,255,255,0.03)';
-  ctx.lineWidth = 1;
-  for (let i = 0; i <= W; i++) {
-    const x = i * cellW;
-    ctx.beginPath(); ctx.moveTo(x, 0); ctx.lineTo(x, canvas.height); ctx.stroke();
+  if (Math.min(cellW, cellH) >= 4) {
+    ctx.strokeStyle = 'rgba(255,255,255,0.03)';
+    ctx.lineWidth = 1;
+    for (let i = Math.floor(view.x); i <= W; i++) {
+      const x = ox + i * cellW;
+      if (x > canvas.width) break;
+      ctx.beginPath(); ctx.moveTo(x, 0); ctx.lineTo(x, canvas.height); ctx.stroke();
+    }
+    for (let j = Math.floor(view.y); j <= H; j++) {
+      const y = oy + j * cellH;
+      if (y > canvas.height) break;
+      ctx.beginPath(); ctx.moveTo(0, y); ctx.lineTo(canvas.width, y); ctx.stroke();
+    }
   }
-  for (let j = 0; j <= H; j++) {
-    const y = j * cellH;
-    ctx.beginPath(); ctx.moveTo(0, y); ctx.lineTo(canvas.width, y); ctx.stroke();
+
+  if (state.tiles) {
+    let maxCount = 1;
+    state.tiles.forEach(t => { maxCount = Math.max(maxCount, t.m + t.f, t.foods); });
+    state.tiles.forEach(t => {
+      const tx = ox + t.x * cellW, ty = oy + t.y * cellH;
+      const tw = t.size * cellW, th = t.size * cellH;
+      if (t.foods) { ctx.fillStyle = `rgba(46,204,113,${0.15 + 0.6 * t.foods / maxCount})`; ctx.fillRect(tx, ty, tw, th); }
+      if (t.m + t.f) { ctx.fillStyle = `rgba(${t.f > t.m ? '219,68,55' : '66,133,244'},${0.3 + 0.7 * (t.m + t.f) / maxCount})`; ctx.fillRect(tx + tw * 0.2, ty + th * 0.2, tw * 0.6, th * 0.6); }
+    });
   }
 
   // draw clouds (semi-transparent)
   if (state.clouds && state.clouds.length) {
     state.clouds.forEach(c => {
-      const cx = (c.x + 0.5) * cellW;
-      const cy = (c.y + 0.5) * cellH;
+      const cx = ox + (c.x + 0.5) * cellW;
+      const cy = oy + (c.y + 0.5) * cellH;
       const r = Math.max(cellW, cellH) * c.r * 0.5;
       ctx.fillStyle = 'rgba(100,150,255,0.06)';
       ctx.beginPath(); ctx.arc(cx, cy, r, 0, Math.PI*2); ctx.fill();
@@ -71,8 +120,8 @@ function renderState(state) {
 
   state.foods.forEach(f => {
     ctx.fillStyle = '#2ecc71';
-    const fx = f.x * cellW + cellW * 0.1;
-    const fy = f.y * cellH + cellH * 0.1;
+    const fx = ox + f.x * cellW + cellW * 0.1;
+    const fy = oy + f.y * cellH + cellH * 0.1;
     const fw = cellW * 0.8;
     const fh = cellH * 0.8;
     ctx.fillRect(fx, fy, Math.max(2, fw), Math.max(2, fh));
@@ -81,12 +130,14 @@ function renderState(state) {
   state.agents.forEach(a => {
     const energy = a.energy || 10
     const size = Math.max(4, Math.min(cellW, cellH) * 0.9 * Math.min(1, energy / 60))
-    const sx = a.x * cellW + (cellW - size) / 2;
-    const sy = a.y * cellH + (cellH - size) / 2;
+    const sx = ox + a.x * cellW + (cellW - size) / 2;
+    const sy = oy + a.y * cellH + (cellH - size) / 2;
     ctx.fillStyle = (a.sex === 'M') ? '#4285f4' : '#db4437';
     ctx.fillRect(sx, sy, size, size);
   });
 
+  renderPolicy(state.policy);
+
   lastLineage = state.lineage || {};
   state.agents.sort((x, y) => x.id - y.id)
   const tbody = document.querySelector('#agentTable tbody');
@@ -94,31 +145,44 @@ function renderState(state) {
   state.agents.slice(0, 500).forEach(a => {
     const tr = document.createElement('tr');
     tr.innerHTML = `<td>${a.id}</td><td>${(a.energy || 0).toFixed(1)}</td><td>${a.age || 0}</td><td>${a.sex || ''}</td><td>${(a.strength || 0).toFixed(2)}</td><td>${(a.agg || 0).toFixed(2)}</td><td>${(a.repro || 0).toFixed(2)}</td>`;
-    tr.onclick = () => { selectedAgent = a.id; renderAgentDetails(a, state); };
+    tr.onclick = () => { selectedAgent = a.id; renderAgentDetails(a, state); ws.send(JSON.stringify({ type: 'inspect_agent', id: a.id })); ws.send(JSON.stringify({ type: 'watch_agent', id: a.id })); };
     tbody.appendChild(tr);
   });
 
   statsEl.innerText = `Population: ${state.metrics.population}  Avg energy: ${state.metrics.avg_energy.toFixed(2)}  Births:${state.metrics.births || 0} Deaths:${state.metrics.deaths || 0} Avg life:${(state.metrics.avg_life || 0).toFixed(1)}`;
+  const dc = state.metrics.deaths_by_cause || {};
+  statsEl.innerText += `\nStarved:${dc.starved || 0} Killed:${dc.killed || 0} Merged:${dc.merged || 0} Old age:${dc.old_age || 0}  Birth rate:${(state.metrics.birth_rate || 0).toFixed(2)} Death rate:${(state.metrics.death_rate || 0).toFixed(2)}`;
+  const lrn = state.metrics.learning;
+  if (lrn) {
+    const wn = lrn.weight_norm || {};
+    statsEl.innerText += `\nEntropy:${lrn.policy_entropy.toFixed(3)} |TD|:${lrn.abs_td.toFixed(3)} Clipped:${(lrn.adv_clip_frac * 100).toFixed(1)}% Weight norm:${(wn.mean || 0).toFixed(2)} (${(wn.min || 0).toFixed(2)}-${(wn.max || 0).toFixed(2)}) Quarantined:${dc.quarantined || 0}`;
+  }
 
+  if (state.events && state.events.length > 0) {
+    events = events.concat(state.events);
+    if (events.length > maxEvents) events = events.slice(events.length - maxEvents);
+  }
   const eventLog = document.getElementById('eventLog');
   const eventCount = document.getElementById('eventCount');
-  if (state.events && state.events.length > 0) {
+  if (events.length > 0) {
     if (eventCount) {
-      eventCount.innerText = `(${state.events.length})`;
+      eventCount.innerText = `(${events.length})`;
     }
     let html = '';
     const colorMap = {
       'kill': '#ff6b6b',
       'death': '#c92a2a',
       'birth': '#51cf66',
-      'eat': '#ffd43b',
+      'food_eaten': '#ffd43b',
+      'food_added': '#94d82d',
+      'agent_spawned': '#74c0fc',
       'attack': '#ff922b',
       'merge': '#da77f2'
     };
     const displayLimit = 100;
-    const startIdx = Math.max(0, state.events.length - displayLimit);
-    for (let i = state.events.length - 1; i >= startIdx; i--) {
-      const e = state.events[i];
+    const startIdx = Math.max(0, events.length - displayLimit);
+    for (let i = events.length - 1; i >= startIdx; i--) {
+      const e = events[i];
       const color = colorMap[e.type] || '#888';
       html += `<div style="color:${color}">⚡ ${e.message}</div>`;
     }
@@ -198,19 +262,117 @@ function renderAgentDetails(agent, state) {
   g.appendChild(list);
 }
 
+function handleReply(r) {
+  if (r.command === 'possess' && r.ok) setPossessed(r.id);
+  if (r.command === 'act' && !r.ok) setPossessed(null);
+  if (!r.ok) { console.warn(`${r.command} failed: ${r.error.code} (${r.error.message})`); statsEl.title = `${r.command}: ${r.error.message}`; return; }
+  if (r.command === 'inspect_agent' && r.id === selectedAgent) renderBiography(r.data);
+}
+
+function renderPolicy(p) {
+  const el = document.getElementById('policy');
+  if (!p) { el.innerHTML = ''; return; }
+  const cells = p.probs.map((v, i) => `<td style="background:rgba(66,133,244,${v.toFixed(2)});${i === p.action ? 'outline:1px solid #fff;' : ''}">${v.toFixed(2)}</td>`);
+  const td = p.td_errors.length ? p.td_errors.reduce((s, v) => s + Math.abs(v), 0) / p.td_errors.length : 0;
+  let html = `<strong>Policy #${p.agent_id}</strong>${p.controlled ? ' (possessed)' : ''}`;
+  html += `<table style="font-size:11px">${[0, 3, 6].map(r => '<tr>' + cells.slice(r, r + 3).join('') + '</tr>').join('')}</table>`;
+  html += `<div style="font-size:11px">features: ${p.features.map(v => v.toFixed(2)).join(' ')}<br>value ${p.value.toFixed(3)} · mean |TD| ${td.toFixed(3)} · reward μ ${p.r_mean.toFixed(2)} σ² ${p.r_var.toFixed(2)}<br>|W| ${p.weight_norm.toFixed(2)} · |critic| ${p.critic_norm.toFixed(2)}</div>`;
+  el.innerHTML = html;
+}
+
+function renderBiography(h) {
+  const g = document.getElementById('genealogy');
+  const bio = document.createElement('div');
+  bio.className = 'geneTree';
+  const last = h.samples.length ? h.samples[h.samples.length - 1] : null;
+  let html = '<ul>';
+  html += `<li><strong>Born:</strong> tick ${h.birth_tick}</li>`;
+  if (!h.alive) html += `<li><strong>Died:</strong> tick ${h.death_tick} at age ${h.death_age} (${h.cause}${h.cause_by ? ' by #' + h.cause_by : ''})</li>`;
+  if (last) html += `<li><strong>Last seen:</strong> (${last.x}, ${last.y}) energy ${last.energy.toFixed(1)}</li>`;
+  html += `<li><strong>Foods eaten:</strong> ${h.foods_eaten}</li>`;
+  html += `<li><strong>Attacks:</strong> ${h.attacks_given} given / ${h.attacks_received} received</li>`;
+  html += `<li><strong>Matings:</strong> ${h.matings} <strong>Merges:</strong> ${h.merges}</li>`;
+  html += `<li><strong>Offspring:</strong> ${(h.offspring || []).join(', ')}</li>`;
+  html += '</ul>';
+  bio.innerHTML = html;
+  g.appendChild(bio);
+}
+
+document.getElementById('followFamily').onclick = () => {
+  if (selectedAgent === null) return;
+  events = [];
+  ws.send(JSON.stringify({ type: 'subscribe', agents: [selectedAgent], follow_family: true }));
+}
+
+document.getElementById('allEvents').onclick = () => {
+  events = [];
+  ws.send(JSON.stringify({ type: 'subscribe' }));
+}
+
+let possessed = null;
+function setPossessed(id) {
+  possessed = id;
+  document.getElementById('possess').innerText = id === null ? 'Possess selected' : `Release #${id} (arrows/WASD)`;
+}
+
+document.getElementById('possess').onclick = () => {
+  if (possessed !== null) { ws.send(JSON.stringify({ type: 'release', id: possessed })); setPossessed(null); return; }
+  if (selectedAgent === null) return;
+  ws.send(JSON.stringify({ type: 'possess', id: selectedAgent }));
+}
+
+const moveKeys = { ArrowUp: [0, -1], ArrowDown: [0, 1], ArrowLeft: [-1, 0], ArrowRight: [1, 0], w: [0, -1], s: [0, 1], a: [-1, 0], d: [1, 0] };
+let lastAct = 0;
+window.addEventListener('keydown', (ev) => {
+  const m = moveKeys[ev.key];
+  if (possessed === null || !m || ev.target.tagName === 'INPUT') return;
+  ev.preventDefault();
+  if (ev.timeStamp - lastAct < 60) return;
+  lastAct = ev.timeStamp;
+  ws.send(JSON.stringify({ type: 'act', id: possessed, dx: m[0], dy: m[1] }));
+});
+
 document.getElementById('pause').onclick = () => { paused = !paused; document.getElementById('pause').innerText = paused ? 'Resume' : 'Pause'; }
 
 window.addEventListener('resize', resize);
 resize();
 
+canvas.addEventListener('wheel', (ev) => {
+  ev.preventDefault();
+  const rect = canvas.getBoundingClientRect();
+  const before = cellSize();
+  const gx = view.x + (ev.clientX - rect.left) / before.cellW;
+  const gy = view.y + (ev.clientY - rect.top) / before.cellH;
+  view.zoom = Math.max(1, Math.min(64, view.zoom * (ev.deltaY < 0 ? 1.25 : 0.8)));
+  const after = cellSize();
+  view.x = gx - (ev.clientX - rect.left) / after.cellW;
+  view.y = gy - (ev.clientY - rect.top) / after.cellH;
+  clampView();
+  sendViewport();
+}, { passive: false });
+
+let drag = null;
+canvas.addEventListener('mousedown', (ev) => { drag = { x: ev.clientX, y: ev.clientY, moved: false }; });
+window.addEventListener('mouseup', () => { setTimeout(() => { drag = null; }, 0); });
+canvas.addEventListener('mousemove', (ev) => {
+  if (!drag) return;
+  const dx = ev.clientX - drag.x, dy = ev.clientY - drag.y;
+  if (!drag.moved && Math.abs(dx) + Math.abs(dy) < 4) return;
+  const { cellW, cellH } = cellSize();
+  view.x -= dx / cellW; view.y -= dy / cellH;
+  drag.x = ev.clientX; drag.y = ev.clientY; drag.moved = true;
+  clampView();
+  sendViewport();
+});
+
 canvas.addEventListener('click', (ev) => {
+  if (drag && drag.moved) return;
   const rect = canvas.getBoundingClientRect();
   const x = ev.clientX - rect.left;
   const y = ev.clientY - rect.top;
-  const cellW = canvas.width / W;
-  const cellH = canvas.height / H;
-  const gx = Math.floor(x / cellW);
-  const gy = Math.floor(y / cellH);
+  const { cellW, cellH } = cellSize();
+  const gx = Math.floor(view.x + x / cellW);
+  const gy = Math.floor(view.y + y / cellH);
   if (addFoodMode) {
     ws.send(JSON.stringify({ type: 'add_food', x: gx, y: gy, energy: 12 }));
     return;
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+const (
+	viewportMargin  = 5
+	minDetailZoom   = 2.0
+	maxDetailCells  = 250 * 250
+	maxDensityTiles = 100 * 100
+	minTilePixels   = 4.0
+)
+
+type viewport struct {
+	Rect sim.Rect `json:"rect"`
+	Zoom float64  `json:"zoom"`
+}
+
+type viewportCmd struct {
+	commandHeader
+	X    int     `json:"x"`
+	Y    int     `json:"y"`
+	W    int     `json:"w"`
+	H    int     `json:"h"`
+	Zoom float64 `json:"zoom"`
+}
+
+func parseViewport(raw []byte) (*viewport, error) {
+	var c viewportCmd
+	if err := decodeStrict(raw, &c); err != nil {
+		return nil, err
+	}
+	if c.W == 0 && c.H == 0 {
+		return nil, nil
+	}
+	if c.W <= 0 || c.H <= 0 {
+		return nil, fieldError(codeInvalidField, "w/h", "must both be positive, or both 0 to clear the viewport")
+	}
+	if c.Zoom < 0 {
+		return nil, fieldError(codeInvalidField, "zoom", "must not be negative")
+	}
+	return &viewport{Rect: sim.Rect{X: c.X, Y: c.Y, W: c.W, H: c.H}, Zoom: c.Zoom}, nil
+}
+
+func (v *viewport) tileSize() int {
+	area := v.Rect.W * v.Rect.H
+	if area <= maxDetailCells && (v.Zoom <= 0 || v.Zoom >= minDetailZoom) {
+		return 0
+	}
+	size := int(math.Ceil(math.Sqrt(float64(area) / maxDensityTiles)))
+	if v.Zoom > 0 {
+		if byZoom := int(math.Ceil(minTilePixels / v.Zoom)); byZoom > size {
+			size = byZoom
+		}
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+func (v *viewport) apply(msg map[string]interface{}) {
+	agents, _ := msg["agents"].([]sim.AgentView)
+	foods, _ := msg["foods"].([]sim.Food)
+	area := v.Rect.Grow(viewportMargin)
+	msg["viewport"] = v
+	if size := v.tileSize(); size > 0 {
+		msg["agents"] = []sim.AgentView{}
+		msg["foods"] = []sim.Food{}
+		msg["tiles"] = sim.DensityTiles(agents, foods, area, size)
+		msg["tile_size"] = size
+		return
+	}
+	visibleAgents := make([]sim.AgentView, 0)
+	for _, a := range agents {
+		if area.Contains(a.X, a.Y) {
+			visibleAgents = append(visibleAgents, a)
+		}
+	}
+	visibleFoods := make([]sim.Food, 0)
+	for _, f := range foods {
+		if area.Contains(f.X, f.Y) {
+			visibleFoods = append(visibleFoods, f)
+		}
+	}
+	msg["agents"] = visibleAgents
+	msg["foods"] = visibleFoods
+}
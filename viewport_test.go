@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vl4deee11/aalive/sim"
+)
+
+func TestParseViewport(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want *viewport
+		code string
+	}{
+		{"clear", `{"type":"viewport"}`, nil, ""},
+		{"clear with offset", `{"type":"viewport","x":5,"y":5,"w":0,"h":0}`, nil, ""},
+		{"rect", `{"type":"viewport","x":1,"y":2,"w":30,"h":40,"zoom":3}`, &viewport{Rect: sim.Rect{X: 1, Y: 2, W: 30, H: 40}, Zoom: 3}, ""},
+		{"zero width", `{"type":"viewport","w":0,"h":50}`, nil, codeInvalidField},
+		{"zero height", `{"type":"viewport","w":50,"h":0}`, nil, codeInvalidField},
+		{"negative width", `{"type":"viewport","w":-1,"h":50}`, nil, codeInvalidField},
+		{"negative zoom", `{"type":"viewport","w":10,"h":10,"zoom":-1}`, nil, codeInvalidField},
+		{"unknown field", `{"type":"viewport","w":10,"h":10,"depth":1}`, nil, codeInvalidField},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseViewport([]byte(tt.raw))
+			code := ""
+			if err != nil {
+				code = toReplyError(err).Code
+			}
+			if code != tt.code || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseViewport = %+v, %v; want %+v, code %q", got, err, tt.want, tt.code)
+			}
+		})
+	}
+}
+
+func TestViewportTileSize(t *testing.T) {
+	tests := []struct {
+		name string
+		v    viewport
+		want int
+	}{
+		{"small rect, no zoom", viewport{Rect: sim.Rect{W: 100, H: 100}}, 0},
+		{"small rect, close zoom", viewport{Rect: sim.Rect{W: 100, H: 100}, Zoom: 8}, 0},
+		{"small rect, far zoom", viewport{Rect: sim.Rect{W: 100, H: 100}, Zoom: 1}, 4},
+		{"huge rect", viewport{Rect: sim.Rect{W: 1000, H: 1000}}, 10},
+		{"huge rect, far zoom", viewport{Rect: sim.Rect{W: 1000, H: 1000}, Zoom: 0.25}, 16},
+	}
+	for _, tt := range tests {
+		if got := tt.v.tileSize(); got != tt.want {
+			t.Errorf("%s: tileSize = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestViewportApply(t *testing.T) {
+	agents := []sim.AgentView{{ID: 1, X: 2, Y: 2, Sex: sim.Female}, {ID: 2, X: 50, Y: 50, Sex: sim.Male}}
+	foods := []sim.Food{{X: 3, Y: 3}, {X: 90, Y: 90}}
+
+	detail := &viewport{Rect: sim.Rect{X: 0, Y: 0, W: 10, H: 10}}
+	msg := map[string]interface{}{"agents": agents, "foods": foods}
+	detail.apply(msg)
+	if got := msg["agents"].([]sim.AgentView); len(got) != 1 || got[0].ID != 1 {
+		t.Fatalf("visible agents = %+v", got)
+	}
+	if got := msg["foods"].([]sim.Food); len(got) != 1 || got[0].X != 3 {
+		t.Fatalf("visible foods = %+v", got)
+	}
+	if _, ok := msg["tiles"]; ok {
+		t.Fatal("detail view sent tiles")
+	}
+
+	far := &viewport{Rect: sim.Rect{X: 0, Y: 0, W: 100, H: 100}, Zoom: 1}
+	msg = map[string]interface{}{"agents": agents, "foods": foods}
+	far.apply(msg)
+	tiles, _ := msg["tiles"].([]sim.DensityTile)
+	if len(msg["agents"].([]sim.AgentView)) != 0 || len(tiles) != 3 || msg["tile_size"] != 4 {
+		t.Fatalf("far view = %+v", msg)
+	}
+}
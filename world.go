@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/vl4deee11/aalive/eventlog"
+	"github.com/vl4deee11/aalive/sim"
+)
+
+const (
+	defaultWorld  = "default"
+	codeConflict  = "conflict"
+	eventLogQueue = 4096
+)
+
+var worldNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+var (
+	errWorldNotFound = errors.New("world not found")
+	errWorldExists   = errors.New("world already exists")
+	errDefaultWorld  = errors.New("the default world cannot be destroyed")
+)
+
+type worldSpec struct {
+	Name string `json:"name"`
+	sim.Config
+}
+
+type worldInfo struct {
+	Name       string     `json:"name"`
+	Created    time.Time  `json:"created"`
+	Clients    int        `json:"clients"`
+	Population int        `json:"population"`
+	Ticks      int        `json:"ticks"`
+	Config     sim.Config `json:"config"`
+}
+
+type world struct {
+	name    string
+	sim     *sim.Sim
+	logDir  string
+	created time.Time
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	clients map[*Client]struct{}
+	closed  bool
+
+	elog        *eventlog.Writer
+	unsubscribe func()
+	logDone     chan struct{}
+}
+
+func (w *world) addClient(c *Client) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return false
+	}
+	w.clients[c] = struct{}{}
+	return true
+}
+
+func (w *world) removeClient(c *Client) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.clients[c]; !ok {
+		return false
+	}
+	delete(w.clients, c)
+	return true
+}
+
+// disconnect drops a client whose connection ended and hands the agents it
+// possessed back to their own policies.
+func (w *world) disconnect(c *Client, metrics *serverMetrics) {
+	if w.removeClient(c) {
+		metrics.clientDisconnected(c)
+	}
+	w.sim.ReleaseOwner(c.id)
+}
+
+func (w *world) clientList() []*Client {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	list := make([]*Client, 0, len(w.clients))
+	for c := range w.clients {
+		list = append(list, c)
+	}
+	return list
+}
+
+func (w *world) info() worldInfo {
+	t := w.sim.Telemetry()
+	return worldInfo{
+		Name:       w.name,
+		Created:    w.created,
+		Clients:    len(w.clientList()),
+		Population: t.Population,
+		Ticks:      t.Ticks,
+		Config:     w.sim.Config(),
+	}
+}
+
+func (w *world) broadcast(metrics *serverMetrics) {
+	defer w.wg.Done()
+	for {
+		var state interface{}
+		select {
+		case <-w.ctx.Done():
+			w.drain(metrics)
+			return
+		case state = <-w.sim.StateChan:
+		}
+		w.send(state, metrics)
+	}
+}
+
+func (w *world) drain(metrics *serverMetrics) {
+	for {
+		select {
+		case state := <-w.sim.StateChan:
+			w.send(state, metrics)
+		default:
+			return
+		}
+	}
+}
+
+func (w *world) send(state interface{}, metrics *serverMetrics) {
+	snapshot, _ := state.(map[string]interface{})
+	for _, c := range w.clientList() {
+		if err := c.SendState(snapshot, w.sim); err != nil {
+			log.Printf("client send error: %v", err)
+			metrics.sendError(c)
+			if w.removeClient(c) {
+				metrics.clientDisconnected(c)
+			}
+			c.conn.Close()
+		}
+	}
+}
+
+func (w *world) startEventLog() error {
+	elog, err := eventlog.Open(eventlog.Config{Dir: w.logDir})
+	if err != nil {
+		return err
+	}
+	after := w.sim.LastEventID()
+	events, unsubscribe := w.sim.Subscribe(eventLogQueue)
+	w.elog = elog
+	w.unsubscribe = unsubscribe
+	w.logDone = make(chan struct{})
+	go func() {
+		elog.Consume(events, after, func(cursor uint64) []sim.Event { return w.sim.EventsSince(cursor, 0) })
+		close(w.logDone)
+	}()
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		lastTick, epoch := 0, w.sim.Epoch()
+		for {
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			lastTick, epoch = w.logMetrics(elog, lastTick, epoch)
+		}
+	}()
+	return nil
+}
+
+// logMetrics appends the metrics samples after lastTick. A reset or resize
+// restarts the tick count, so a new epoch starts again from tick 0.
+func (w *world) logMetrics(elog *eventlog.Writer, lastTick, epoch int) (int, int) {
+	if e := w.sim.Epoch(); e != epoch {
+		lastTick, epoch = 0, e
+	}
+	samples, _ := w.sim.MetricsHistory(lastTick+1, 0, 10)
+	for _, m := range samples {
+		if err := elog.WriteMetrics(m); err != nil {
+			log.Printf("event log: %v", err)
+		}
+		lastTick = m.Tick
+	}
+	return lastTick, epoch
+}
+
+func (w *world) stop(metrics *serverMetrics, reason string) {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	w.cancel()
+	w.wg.Wait()
+	for _, c := range w.clientList() {
+		if w.removeClient(c) {
+			metrics.clientDisconnected(c)
+		}
+		c.Close(websocket.CloseGoingAway, reason)
+	}
+	if w.unsubscribe != nil {
+		w.unsubscribe()
+		<-w.logDone
+	}
+	if w.elog != nil {
+		if err := w.elog.Close(); err != nil {
+			log.Printf("event log: %v", err)
+		}
+	}
+}
+
+type worldManager struct {
+	mu       sync.Mutex
+	worlds   map[string]*world
+	logDir   string
+	defaults sim.Config
+	metrics  *serverMetrics
+}
+
+func newWorldManager(logDir string, defaults sim.Config, metrics *serverMetrics) *worldManager {
+	return &worldManager{worlds: make(map[string]*world), logDir: logDir, defaults: defaults, metrics: metrics}
+}
+
+func (m *worldManager) create(spec worldSpec) (*world, error) {
+	if !worldNameRe.MatchString(spec.Name) {
+		return nil, fieldError(codeInvalidField, "name", "must be 1-32 letters, digits, '-' or '_'")
+	}
+	if err := spec.Config.Validate(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.worlds[spec.Name]; ok {
+		return nil, errWorldExists
+	}
+	return m.add(spec.Name, sim.NewSimWithConfig(spec.Config))
+}
+
+func (m *worldManager) add(name string, s *sim.Sim) (*world, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &world{
+		name:    name,
+		sim:     s,
+		created: time.Now(),
+		ctx:     ctx,
+		cancel:  cancel,
+		clients: make(map[*Client]struct{}),
+	}
+	if m.logDir != "" {
+		w.logDir = m.logDir
+		if name != defaultWorld {
+			w.logDir = filepath.Join(m.logDir, name)
+		}
+		if err := w.startEventLog(); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	m.worlds[name] = w
+	w.wg.Add(2)
+	go func() {
+		defer w.wg.Done()
+		w.sim.Run(ctx)
+	}()
+	go w.broadcast(m.metrics)
+	return w, nil
+}
+
+func (m *worldManager) get(name string) (*world, bool) {
+	if name == "" {
+		name = defaultWorld
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.worlds[name]
+	return w, ok
+}
+
+func (m *worldManager) list() []*world {
+	m.mu.Lock()
+	out := make([]*world, 0, len(m.worlds))
+	for _, w := range m.worlds {
+		out = append(out, w)
+	}
+	m.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+func (m *worldManager) destroy(name string) error {
+	if name == defaultWorld {
+		return errDefaultWorld
+	}
+	m.mu.Lock()
+	w, ok := m.worlds[name]
+	delete(m.worlds, name)
+	m.mu.Unlock()
+	if !ok {
+		return errWorldNotFound
+	}
+	w.stop(m.metrics, "world destroyed")
+	return nil
+}
+
+func (m *worldManager) shutdown(snapshotDir string) {
+	m.mu.Lock()
+	list := make([]*world, 0, len(m.worlds))
+	for name, w := range m.worlds {
+		list = append(list, w)
+		delete(m.worlds, name)
+	}
+	m.mu.Unlock()
+	for _, w := range list {
+		w.stop(m.metrics, "server shutting down")
+		if snapshotDir == "" {
+			continue
+		}
+		if err := saveSnapshot(snapshotDir, w); err != nil {
+			log.Printf("snapshot %s: %v", w.name, err)
+		} else {
+			log.Printf("saved world %s to %s", w.name, snapshotDir)
+		}
+	}
+}
+
+func snapshotPath(dir, name string) string {
+	return filepath.Join(dir, name+".json")
+}
+
+func saveSnapshot(dir string, w *world) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := snapshotPath(dir, w.name)
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err := w.sim.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (m *worldManager) loadSnapshots(dir string) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		if !worldNameRe.MatchString(name) {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return n, err
+		}
+		s, err := sim.LoadSim(f)
+		f.Close()
+		if err != nil {
+			return n, fmt.Errorf("%s: %w", path, err)
+		}
+		m.mu.Lock()
+		_, err = m.add(name, s)
+		m.mu.Unlock()
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vl4deee11/aalive/eventlog"
+	"github.com/vl4deee11/aalive/sim"
+)
+
+func loggedMetricTicks(t *testing.T, dir string) []int {
+	t.Helper()
+	fh, err := os.Open(filepath.Join(dir, "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	var ticks []int
+	sc := bufio.NewScanner(fh)
+	for sc.Scan() {
+		var r struct {
+			Kind    string             `json:"kind"`
+			Metrics *sim.MetricsSample `json:"metrics"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Kind == "metrics" {
+			ticks = append(ticks, r.Metrics.Tick)
+		}
+	}
+	return ticks
+}
+
+func TestLogMetricsAfterReset(t *testing.T) {
+	cfg := sim.DefaultConfig()
+	cfg.Seed, cfg.Width, cfg.Height, cfg.InitialAgents = 5, 20, 20, 5
+	w := &world{sim: sim.NewSimWithConfig(cfg)}
+	dir := t.TempDir()
+	elog, err := eventlog.Open(eventlog.Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lastTick, epoch := 0, w.sim.Epoch()
+	tickN(w.sim, 40)
+	lastTick, epoch = w.logMetrics(elog, lastTick, epoch)
+	if lastTick != 40 {
+		t.Fatalf("cursor after 40 ticks = %d", lastTick)
+	}
+	if _, err := w.sim.Reset(cfg); err != nil {
+		t.Fatal(err)
+	}
+	tickN(w.sim, 20)
+	lastTick, epoch = w.logMetrics(elog, lastTick, epoch)
+	tickN(w.sim, 10)
+	lastTick, _ = w.logMetrics(elog, lastTick, epoch)
+	if err := elog.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int{10, 20, 30, 40, 10, 20, 30}
+	got := loggedMetricTicks(t, dir)
+	if len(got) != len(want) {
+		t.Fatalf("logged ticks %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("logged ticks %v, want %v", got, want)
+		}
+	}
+	if lastTick != 30 {
+		t.Fatalf("final cursor = %d, want 30", lastTick)
+	}
+}
+
+func TestDisconnectReleasesAgents(t *testing.T) {
+	_, wd := newTestAPI(t, &authConfig{anonymous: roleViewer})
+	metrics := newServerMetrics()
+	agents := wd.sim.Agents()
+	a, b := agents[0].ID, agents[1].ID
+	first := &Client{id: 1, role: roleOperator}
+	second := &Client{id: 2, role: roleOperator}
+	for _, c := range []*Client{first, second} {
+		wd.addClient(c)
+		metrics.clientConnected(c)
+	}
+
+	tests := []struct {
+		name   string
+		client *Client
+		cmd    string
+		code   string
+	}{
+		{"first possesses a", first, fmt.Sprintf(`{"type":"possess","id":%d}`, a), ""},
+		{"second cannot take a", second, fmt.Sprintf(`{"type":"possess","id":%d}`, a), codeConflict},
+		{"second cannot steer a", second, fmt.Sprintf(`{"type":"act","id":%d,"action":4}`, a), codeConflict},
+		{"second cannot release a", second, fmt.Sprintf(`{"type":"release","id":%d}`, a), codeConflict},
+		{"second possesses b", second, fmt.Sprintf(`{"type":"possess","id":%d}`, b), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := tt.client.execute(wd.sim, []byte(tt.cmd))
+			code := ""
+			if rep.Error != nil {
+				code = rep.Error.Code
+			}
+			if code != tt.code {
+				t.Fatalf("reply = %+v, want %q", rep, tt.code)
+			}
+		})
+	}
+
+	wd.disconnect(first, metrics)
+	if got := wd.sim.Controlled(); len(got) != 1 || got[0] != b {
+		t.Fatalf("controlled after disconnect = %v, want only %d", got, b)
+	}
+	if len(wd.clientList()) != 1 || metrics.clients != 1 {
+		t.Fatalf("clients after disconnect = %d, metrics %d", len(wd.clientList()), metrics.clients)
+	}
+	if rep := second.execute(wd.sim, []byte(fmt.Sprintf(`{"type":"possess","id":%d}`, a))); !rep.OK {
+		t.Fatalf("possess after the owner left = %+v", rep)
+	}
+	wd.disconnect(second, metrics)
+	if got := wd.sim.Controlled(); len(got) != 0 {
+		t.Fatalf("controlled after everyone left = %v", got)
+	}
+}